@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"cryptoquip/qp"
+)
+
+const help = `x=g        solve cipher letter x as clear letter g
+undo       take back the last letter solved
+? x        clear letters cipher letter x could still be
+fit word   dictionary words that still fit cipher word
+hint       the letter the solver finds most forced
+show       redraw the puzzle
+quit       stop`
+
+func main() {
+	dictName := flag.String("d", "/usr/share/dict/words", "cleartext dictionary")
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	puzzleName := flag.String("p", "", "puzzle file name")
+	encodeSelf := flag.Bool("s", false, "cipher letter can encode itself, real default true")
+	flag.Parse()
+	*encodeSelf = !*encodeSelf
+
+	if *puzzleName == "" {
+		log.Fatal("need a puzzle file name")
+	}
+	puzzles, err := qp.ReadPuzzles(*puzzleName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(puzzles) == 0 {
+		log.Fatalf("%s has no cipher words", *puzzleName)
+	}
+	puzzle := puzzles[0]
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dict, err := qp.LoadDictionary(*dictName, alphabet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dict.IndexError != nil {
+		log.Printf("not using shape index: %v", dict.IndexError)
+	}
+	solver := &qp.Solver{ShapeDict: dict.Shapes, Counts: dict.Counts, Alphabet: dict.Alphabet}
+
+	assistant, err := solver.NewAssistant(puzzle, *encodeSelf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(help)
+	fmt.Println()
+	redraw(assistant)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch {
+		case strings.Contains(line, "="):
+			cipherLetter, clearLetter, ok := parseAssignment(line)
+			if !ok {
+				fmt.Printf("can't make sense of %q, try x=g\n", line)
+				continue
+			}
+			if err := assistant.Assign(cipherLetter, clearLetter); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			redraw(assistant)
+			if assistant.Complete() {
+				fmt.Println("every cipher letter has a clear letter")
+			}
+		case fields[0] == "undo":
+			undone, ok := assistant.Undo()
+			if !ok {
+				fmt.Println("nothing to undo")
+				continue
+			}
+			fmt.Printf("%c no longer %c\n", undone.CipherLetter, undone.ClearLetter)
+			redraw(assistant)
+		case fields[0] == "?" && len(fields) == 2:
+			cipherLetter, _ := utf8.DecodeRuneInString(fields[1])
+			fmt.Printf("%c could be: %s\n", cipherLetter, string(assistant.Candidates(cipherLetter)))
+		case fields[0] == "fit" && len(fields) == 2:
+			fitting := assistant.WordCandidates(fields[1])
+			for _, word := range fitting {
+				fmt.Printf("\t%s\n", word)
+			}
+			fmt.Printf("%d dictionary words fit %s\n", len(fitting), fields[1])
+		case fields[0] == "hint":
+			deduction, ok, err := assistant.Hint()
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if !ok {
+				fmt.Println("no letter is forced, try guessing one")
+				continue
+			}
+			fmt.Printf("%c = %c: %v\n", deduction.CipherLetter, deduction.ClearLetter, deduction.Reason)
+		case fields[0] == "show":
+			redraw(assistant)
+		case fields[0] == "help":
+			fmt.Println(help)
+		case fields[0] == "quit":
+			return
+		default:
+			fmt.Printf("unknown command %q, try help\n", line)
+		}
+	}
+}
+
+// parseAssignment picks apart something like "x=g" or "x = g".
+func parseAssignment(line string) (rune, rune, bool) {
+	cipher, clear, _ := strings.Cut(line, "=")
+	cipher, clear = strings.TrimSpace(cipher), strings.TrimSpace(clear)
+	if utf8.RuneCountInString(cipher) != 1 || utf8.RuneCountInString(clear) != 1 {
+		return 0, 0, false
+	}
+	cipherLetter, _ := utf8.DecodeRuneInString(cipher)
+	clearLetter, _ := utf8.DecodeRuneInString(clear)
+	return cipherLetter, clearLetter, true
+}
+
+// redraw prints the cipher words with the partially deciphered words
+// underneath, wrapping long puzzles, and any cipher words no
+// dictionary word fits.
+func redraw(assistant *qp.Assistant) {
+	var cipherLine, clearLine string
+	for i, clear := range assistant.Words() {
+		cipher := string(assistant.Puzzle.Words[i])
+		if len(cipherLine) > 0 && utf8.RuneCountInString(cipherLine)+utf8.RuneCountInString(cipher) > 72 {
+			fmt.Printf("%s\n%s\n\n", cipherLine, clearLine)
+			cipherLine, clearLine = "", ""
+		}
+		if len(cipherLine) > 0 {
+			cipherLine += " "
+			clearLine += " "
+		}
+		cipherLine += cipher
+		clearLine += clear
+	}
+	fmt.Printf("%s\n%s\n\n", cipherLine, clearLine)
+	for _, word := range assistant.Unfit() {
+		fmt.Printf("no dictionary word fits %s any more\n", word)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cryptoquip/qp"
+)
+
+// Accuracy benchmark: solve puzzles with known solutions, and report
+// how many letters the solver got right, got wrong, and left unsolved,
+// for comparing dictionaries, options and changes to the solver.
+func main() {
+	dictName := flag.String("d", "/usr/share/dict/words", "cleartext dictionary")
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	cycles := flag.Int("c", 8, "number of cycles to attempt")
+	encodeSelf := flag.Bool("s", false, "cipher letter can encode itself, real default true")
+	backtrack := flag.Bool("b", true, "backtracking search when cycles stop making progress")
+	missing := flag.Int("miss", 0, "number of cipher words that might not be in the dictionary")
+	workers := flag.Int("j", 1, "number of puzzles to solve at a time")
+	format := flag.String("format", "text", "output format: text, or json for a single JSON document")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] puzzles.txt [puzzles.txt...]\n", filepath.Base(flag.CommandLine.Name()))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *format != "text" && *format != "json" {
+		log.Fatalf("unknown output format %q", *format)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var puzzles []*qp.Puzzle
+	var names []string
+	skipped := 0
+	for _, fileName := range flag.Args() {
+		filePuzzles, err := qp.ReadPuzzles(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, puzzle := range filePuzzles {
+			if len(puzzle.Known) == 0 {
+				// nothing to score it against
+				skipped++
+				continue
+			}
+			puzzles = append(puzzles, puzzle)
+			names = append(names, fmt.Sprintf("%s:%d", fileName, puzzle.Line))
+		}
+	}
+	if len(puzzles) == 0 {
+		log.Fatal("no puzzles with known solutions")
+	}
+
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dict, err := qp.LoadDictionary(*dictName, alphabet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dict.IndexError != nil {
+		log.Printf("not using shape index: %v", dict.IndexError)
+	}
+	solver := &qp.Solver{ShapeDict: dict.Shapes, Counts: dict.Counts, Alphabet: dict.Alphabet}
+	opts := qp.Options{
+		Cycles:     *cycles,
+		EncodeSelf: !*encodeSelf,
+		Backtrack:  *backtrack,
+		Missing:    *missing,
+	}
+
+	start := time.Now()
+	results := solver.SolveBatch(context.Background(), puzzles, opts, *workers)
+	wall := time.Since(start)
+
+	var total qp.Accuracy
+	var elapsed time.Duration
+	solved, cycleCount := 0, 0
+	accuracies := make([]qp.Accuracy, len(results))
+	for n, r := range results {
+		accuracies[n], _ = r.Accuracy()
+		total = total.Add(accuracies[n])
+		if accuracies[n].Wrong == 0 && accuracies[n].Unsolved == 0 {
+			solved++
+		}
+		if r.Result != nil {
+			cycleCount += r.Result.Cycles
+		}
+		elapsed += r.Elapsed
+	}
+
+	if *format == "json" {
+		type benchPuzzle struct {
+			Name      string  `json:"name"`
+			Right     int     `json:"right"`
+			Wrong     int     `json:"wrong"`
+			Unsolved  int     `json:"unsolved"`
+			Cycles    int     `json:"cycles"`
+			ElapsedMS float64 `json:"elapsed_ms"`
+			Error     string  `json:"error,omitempty"`
+		}
+		type benchSummary struct {
+			Puzzles     int     `json:"puzzles"`
+			Skipped     int     `json:"skipped"`
+			FullySolved int     `json:"fully_solved"`
+			SolvedRate  float64 `json:"solved_rate"`
+			Right       int     `json:"right"`
+			Wrong       int     `json:"wrong"`
+			Unsolved    int     `json:"unsolved"`
+			Cycles      int     `json:"cycles"`
+			ElapsedMS   float64 `json:"elapsed_ms"`
+			WallMS      float64 `json:"wall_ms"`
+		}
+		var doc struct {
+			Puzzles []benchPuzzle `json:"puzzles"`
+			Summary benchSummary  `json:"summary"`
+		}
+		for n, r := range results {
+			bp := benchPuzzle{
+				Name:      names[n],
+				Right:     accuracies[n].Right,
+				Wrong:     accuracies[n].Wrong,
+				Unsolved:  accuracies[n].Unsolved,
+				ElapsedMS: milliseconds(r.Elapsed),
+			}
+			if r.Result != nil {
+				bp.Cycles = r.Result.Cycles
+			}
+			if r.Err != nil {
+				bp.Error = r.Err.Error()
+			}
+			doc.Puzzles = append(doc.Puzzles, bp)
+		}
+		doc.Summary = benchSummary{
+			Puzzles:     len(results),
+			Skipped:     skipped,
+			FullySolved: solved,
+			SolvedRate:  float64(solved) / float64(len(results)),
+			Right:       total.Right,
+			Wrong:       total.Wrong,
+			Unsolved:    total.Unsolved,
+			Cycles:      cycleCount,
+			ElapsedMS:   milliseconds(elapsed),
+			WallMS:      milliseconds(wall),
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PUZZLE\tRIGHT\tWRONG\tUNSOLVED\tCYCLES\tTIME\tERROR")
+	for n, r := range results {
+		a := accuracies[n]
+		cycles, errText := "-", ""
+		if r.Result != nil {
+			cycles = fmt.Sprint(r.Result.Cycles)
+		}
+		if r.Err != nil {
+			errText = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%v\t%s\n",
+			names[n], a.Right, a.Wrong, a.Unsolved, cycles, r.Elapsed.Round(time.Microsecond), errText)
+	}
+	tw.Flush()
+
+	fmt.Printf("\n%d puzzles, %d/%d fully solved (%.1f%%)\n",
+		len(results), solved, len(results), percent(solved, len(results)))
+	if skipped > 0 {
+		fmt.Printf("%d puzzles without known solutions skipped\n", skipped)
+	}
+	fmt.Printf("%d letters: %d right (%.1f%%), %d wrong (%.1f%%), %d unsolved (%.1f%%)\n",
+		total.Letters(),
+		total.Right, percent(total.Right, total.Letters()),
+		total.Wrong, percent(total.Wrong, total.Letters()),
+		total.Unsolved, percent(total.Unsolved, total.Letters()))
+	fmt.Printf("%d cycles, %.1f a puzzle\n", cycleCount, float64(cycleCount)/float64(len(results)))
+	fmt.Printf("solving time %v, %v a puzzle, wall time %v\n",
+		elapsed.Round(time.Millisecond), (elapsed / time.Duration(len(results))).Round(time.Microsecond),
+		wall.Round(time.Millisecond))
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"cryptoquip/qp"
+)
+
+func main() {
+	dictName := flag.String("d", "/usr/share/dict/words", "cleartext dictionary")
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	indexName := flag.String("o", "", "shape-index file name, default dictionary name plus "+qp.IndexSuffix)
+	flag.Parse()
+
+	if *indexName == "" {
+		*indexName = *dictName + qp.IndexSuffix
+	}
+
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
+	if err != nil {
+		log.Fatal(err)
+	}
+	header, err := qp.CompileIndex(*dictName, *indexName, alphabet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%s: %d %s words from %s, sha256 %x\n", *indexName, header.Words, header.Alphabet, header.Source, header.SourceHash)
+}
@@ -1,76 +1,47 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
-	"sort"
+	"strings"
 	"time"
-	"unicode"
+
+	"cryptoquip/qp"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	flag.Parse()
+	if flag.NArg() < 1 {
 		fmt.Fprintf(os.Stderr, "Need filename on command line\n")
 		return
 	}
 
-	buf, err := os.ReadFile(os.Args[1])
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	txp := makeTranspose()
-	clearLetters := make(map[rune]bool)
-
-	for _, b := range buf {
-		c := rune(b)
-		if 'a' <= c && c <= 'z' {
-			clearLetters[c] = true
-			c = txp[c]
-		} else if 'A' <= c && c <= 'Z' {
-			clearLetters[c] = true
-			c = txp[unicode.ToLower(c)]
-		}
-		fmt.Printf("%c", c)
-	}
-
-	clears := make([]int, len(clearLetters))
-	cnt := 0
-	for cl, _ := range clearLetters {
-		clears[cnt] = int(cl)
-		cnt++
+	buf, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	sort.Ints(clears)
+	txp := qp.RandomKey(alphabet, rand.New(rand.NewSource(time.Now().UnixNano()+int64(os.Getpid()))))
+	cipherText, clears := qp.Encipher(alphabet, string(buf), txp)
+	fmt.Print(cipherText)
 
 	clearText := "# clear  "
-	cipherText := "# cipher "
-	for i := range clears {
-		clear := rune(clears[i])
+	cipherLetters := "# cipher "
+	for _, clear := range clears {
 		cipher := txp[clear]
 		fmt.Printf("#%c=%c\n", cipher, clear)
 		clearText = fmt.Sprintf("%s %c", clearText, clear)
-		cipherText = fmt.Sprintf("%s %c", cipherText, cipher)
+		cipherLetters = fmt.Sprintf("%s %c", cipherLetters, cipher)
 	}
 	fmt.Println(clearText)
-	fmt.Println(cipherText)
-}
-
-func makeTranspose() map[rune]rune {
-	rand.Seed(time.Now().UnixNano() + int64(os.Getpid()))
-	assoc := make(map[rune]rune)
-	for r := 'a'; r <= 'z'; r++ {
-	OUT:
-		for {
-			offset := rand.Intn(int('z'-'a') + 1)
-			x := rune('a' + offset)
-			if _, ok := assoc[x]; !ok {
-				assoc[x] = r
-				break OUT
-			}
-		}
-	}
-	return assoc
+	fmt.Println(cipherLetters)
 }
@@ -1,28 +1,42 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"cryptoquip/qp"
 )
 
 func main() {
-	if len(os.Args) < 3 {
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	flag.Parse()
+	if flag.NArg() < 2 {
 		fmt.Fprintf(os.Stderr, "Find matches in dictionary by word shape\n")
-		fmt.Fprintf(os.Stderr, "usage: %s cleartext.dictionary word [word...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s [-lang language] [-fold] cleartext.dictionary word [word...]\n", os.Args[0])
 		return
 	}
 
-	dict, err := qp.NewShapeDict(os.Args[1])
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
 	if err != nil {
 		log.Fatal(err)
 	}
+	d, err := qp.LoadDictionary(flag.Arg(0), alphabet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if d.IndexError != nil {
+		log.Printf("not using shape index: %v", d.IndexError)
+	}
+	dict := d.Shapes
 
-	allLetters := qp.NewRunesDict(dict)
+	allLetters := d.RunesDict()
 
-	for _, str := range os.Args[2:] {
+	for _, str := range flag.Args()[1:] {
+		str = d.Alphabet.Normalize(str)
 		config := qp.StringConfiguration(str)
 		fmt.Printf("%s\n%s\n\n", str, config)
 
@@ -36,7 +50,7 @@ func main() {
 			fmt.Printf("Found letters for configuration %s\n", config)
 			for i := 0; i < entry.Length; i++ {
 				fmt.Printf("Letters at %d: ", i)
-				for r, _ := range entry.Runes[i] {
+				for _, r := range d.Alphabet.Runes(entry.Runes[i]) {
 					fmt.Printf("%c ", r)
 				}
 				fmt.Println()
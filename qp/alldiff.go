@@ -0,0 +1,182 @@
+package qp
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// hallSetLimit is the most cipher letters, or clear letters, that
+// allDifferent looks at together. Bigger sets rarely narrow anything
+// the cycles don't, and the number of combinations grows fast.
+const hallSetLimit = 4
+
+// allDifferent narrows the candidate clear letters of the unsolved
+// cipher letters in possibleLetters, since no two cipher letters have
+// the same clear letter. It returns the Reason for each cipher letter
+// it narrowed, for marking the ones left with a single letter.
+//
+// A Hall set is k cipher letters whose candidates add up to only k clear
+// letters, {t,h} for both x and y say. Those k clear letters belong to
+// those k cipher letters, whichever way round, so no other cipher
+// letter can have them. A single cipher letter with a single candidate
+// is a Hall set of 1, which is the same as solving it.
+//
+// Going the other way, k clear letters that only k cipher letters have
+// as candidates, a hidden set, would limit those cipher letters to those
+// clear letters. That's only sound when the clear letters have to get
+// used, and a puzzle doesn't have to use every letter of the alphabet.
+// allDifferent only applies hidden sets when the unsolved cipher letters
+// have exactly as many candidate clear letters among them as there are
+// unsolved cipher letters, so that every candidate clear letter
+// solves some cipher letter.
+func allDifferent(solved *Solved, possibleLetters map[rune]LetterSet) map[rune]Reason {
+	w := solved.out()
+	ab := solved.Alphabet
+	narrowed := make(map[rune]Reason)
+
+	var unsolved []rune
+	everyLetter := true // whether all unsolved cipher letters have candidates
+	for _, c := range solved.CipherLetters {
+		if _, ok := solved.SolvedLetters[c]; ok {
+			continue
+		}
+		m := possibleLetters[c] &^ solved.ClearLetters
+		if m == 0 {
+			// Not in any cipher word with same-shape dictionary
+			// words, or no candidates left, which cwMustMatch reports.
+			everyLetter = false
+			continue
+		}
+		possibleLetters[c] = m
+		unsolved = append(unsolved, c)
+	}
+
+	for progress := true; progress; {
+		progress = false
+
+		sets := make([]uint64, len(unsolved))
+		for i, c := range unsolved {
+			sets[i] = uint64(possibleLetters[c])
+		}
+		smallUnions(sets, hallSetLimit, func(hall []int, union uint64) bool {
+			cipherLetters := pickLetters(unsolved, hall)
+			clearLetters := LetterSet(union)
+			if len(hall) > clearLetters.Len() {
+				solved.problem(fmt.Errorf("%s have only %s among them",
+					listLetters("cipher letter", string(cipherLetters)), listLetters("clear letter", ab.SetString(clearLetters))))
+				return true
+			}
+			reason := Reason{Kind: HallSetReason, Letters: string(cipherLetters), Clear: ab.SetString(clearLetters)}
+			for i, c := range unsolved {
+				m := possibleLetters[c]
+				if containsIndex(hall, i) || m&clearLetters == 0 {
+					continue
+				}
+				if m&^clearLetters == 0 {
+					solved.problem(fmt.Errorf("Hall set of %s leaves cipher letter %c no clear letters",
+						listLetters("cipher letter", string(cipherLetters)), c))
+					return true
+				}
+				fmt.Fprintf(w, "removing %s from cipher letter %c, Hall set of %s\n",
+					listLetters("clear letter", ab.SetString(m&clearLetters)), c, listLetters("cipher letter", string(cipherLetters)))
+				possibleLetters[c] = m &^ clearLetters
+				narrowed[c] = reason
+				progress = true
+			}
+			return progress
+		})
+		if progress || !everyLetter || len(unsolved) > 64 {
+			continue
+		}
+
+		var union LetterSet
+		for _, c := range unsolved {
+			union |= possibleLetters[c]
+		}
+		if union.Len() != len(unsolved) {
+			continue
+		}
+		// Every candidate clear letter gets used. Find the cipher
+		// letters that could be each clear letter, as bits of unsolved.
+		clear := ab.Runes(union)
+		holders := make([]uint64, len(clear))
+		for j, l := range clear {
+			for i, c := range unsolved {
+				if ab.Has(possibleLetters[c], l) {
+					holders[j] |= 1 << i
+				}
+			}
+		}
+		smallUnions(holders, hallSetLimit, func(hidden []int, union uint64) bool {
+			clearLetters := ab.Set(pickLetters(clear, hidden)...)
+			var cipherLetters []rune
+			for rest := union; rest != 0; rest &= rest - 1 {
+				cipherLetters = append(cipherLetters, unsolved[bits.TrailingZeros64(rest)])
+			}
+			if len(hidden) > len(cipherLetters) {
+				solved.problem(fmt.Errorf("%s could only be %s",
+					listLetters("clear letter", ab.SetString(clearLetters)), listLetters("cipher letter", string(cipherLetters))))
+				return true
+			}
+			reason := Reason{Kind: HiddenSetReason, Letters: string(cipherLetters), Clear: ab.SetString(clearLetters)}
+			for _, c := range cipherLetters {
+				m := possibleLetters[c]
+				if m&^clearLetters == 0 {
+					continue
+				}
+				fmt.Fprintf(w, "limiting cipher letter %c to %s, hidden set of %s\n",
+					c, listLetters("clear letter", ab.SetString(m&clearLetters)), listLetters("cipher letter", string(cipherLetters)))
+				possibleLetters[c] = m & clearLetters
+				narrowed[c] = reason
+				progress = true
+			}
+			return progress
+		})
+	}
+
+	return narrowed
+}
+
+// smallUnions calls found with each combination of up to limit of
+// masks whose union has no more bits set than the combination has
+// masks, until found returns true.
+func smallUnions(masks []uint64, limit int, found func(combo []int, union uint64) bool) bool {
+	combo := make([]int, 0, limit)
+	var search func(start int, union uint64) bool
+	search = func(start int, union uint64) bool {
+		for i := start; i < len(masks); i++ {
+			u := union | masks[i]
+			n := bits.OnesCount64(u)
+			if n > limit {
+				continue
+			}
+			combo = append(combo, i)
+			if n <= len(combo) && found(combo, u) {
+				return true
+			}
+			if len(combo) < limit && search(i+1, u) {
+				return true
+			}
+			combo = combo[:len(combo)-1]
+		}
+		return false
+	}
+	return search(0, 0)
+}
+
+func pickLetters(letters []rune, indexes []int) []rune {
+	picked := make([]rune, len(indexes))
+	for i, idx := range indexes {
+		picked[i] = letters[idx]
+	}
+	return picked
+}
+
+func containsIndex(indexes []int, idx int) bool {
+	for _, i := range indexes {
+		if i == idx {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package qp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllDifferent(t *testing.T) {
+	tests := []struct {
+		name      string
+		possible  map[rune]string // candidate clear letters of each cipher letter
+		want      map[rune]string // candidates after allDifferent
+		wantKinds map[rune]ReasonKind
+	}{
+		{
+			// x and y are t and h, whichever way round
+			name:      "Hall set",
+			possible:  map[rune]string{'x': "ht", 'y': "ht", 'z': "aeht"},
+			want:      map[rune]string{'x': "ht", 'y': "ht", 'z': "ae"},
+			wantKinds: map[rune]ReasonKind{'z': HallSetReason},
+		},
+		{
+			// Every candidate gets used, and only u could be a. The
+			// other 5 cipher letters are a Hall set too big to look for.
+			name: "hidden set",
+			possible: map[rune]string{
+				'u': "abcdef", 'v': "bcdef", 'w': "bcdef",
+				'x': "bcdef", 'y': "bcdef", 'z': "bcdef",
+			},
+			want: map[rune]string{
+				'u': "a", 'v': "bcdef", 'w': "bcdef",
+				'x': "bcdef", 'y': "bcdef", 'z': "bcdef",
+			},
+			wantKinds: map[rune]ReasonKind{'u': HiddenSetReason},
+		},
+		{
+			// Same as above, but g might not be in the puzzle, so
+			// a doesn't have to get used
+			name: "no hidden set unless every letter gets used",
+			possible: map[rune]string{
+				'u': "abcdef", 'v': "bcdefg", 'w': "bcdef",
+				'x': "bcdef", 'y': "bcdef", 'z': "bcdef",
+			},
+			want: map[rune]string{
+				'u': "abcdef", 'v': "bcdefg", 'w': "bcdef",
+				'x': "bcdef", 'y': "bcdef", 'z': "bcdef",
+			},
+			wantKinds: map[rune]ReasonKind{},
+		},
+	}
+	for _, tt := range tests {
+		solved := &Solved{SolvedLetters: make(map[rune]rune)}
+		possibleLetters := make(map[rune]LetterSet)
+		for c, letters := range tt.possible {
+			solved.CipherLetters = append(solved.CipherLetters, c)
+			possibleLetters[c] = solved.Alphabet.Set([]rune(letters)...)
+		}
+		solved.Alphabet.sortLetters(solved.CipherLetters)
+
+		narrowed := allDifferent(solved, possibleLetters)
+		got := make(map[rune]string)
+		for c, m := range possibleLetters {
+			got[c] = solved.Alphabet.SetString(m)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: candidates %q, want %q", tt.name, got, tt.want)
+		}
+		kinds := make(map[rune]ReasonKind)
+		for c, reason := range narrowed {
+			kinds[c] = reason.Kind
+		}
+		if !reflect.DeepEqual(kinds, tt.wantKinds) {
+			t.Errorf("%s: narrowed %v, want %v", tt.name, kinds, tt.wantKinds)
+		}
+	}
+}
+
+func TestSmallUnions(t *testing.T) {
+	masks := []uint64{0b0011, 0b0011, 0b0111, 0b1000}
+	var got [][]int
+	smallUnions(masks, 3, func(combo []int, union uint64) bool {
+		got = append(got, append([]int(nil), combo...))
+		return false
+	})
+	// combinations of up to 3 masks with no more bits than masks
+	want := [][]int{{0, 1}, {0, 1, 2}, {0, 1, 3}, {3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("smallUnions found %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,393 @@
+package qp
+
+import (
+	"bufio"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Alphabet is the clear text letters of a language, in alphabetical
+// order. It decides which letters of dictionary words count, and which
+// bit of a LetterSet each letter gets: bit n is the alphabet's n'th
+// letter, and the bit after the last letter is the apostrophe.
+//
+// Methods of a nil *Alphabet work like English.
+type Alphabet struct {
+	Name    string
+	letters []rune
+	lookup  []uint8 // bit+1 of each rune up to the alphabet's last, 0 for none
+	fold    bool
+}
+
+// maxAlphabetLetters leaves a LetterSet room for the apostrophe.
+const maxAlphabetLetters = 63
+
+// language is a built-in alphabet, or one from an alphabet file.
+// Letters with accents go in both letters and accented, so that
+// folding can leave them out.
+type language struct {
+	name, letters, accented string
+}
+
+var languages = []language{
+	{"english", "abcdefghijklmnopqrstuvwxyz", ""},
+	{"spanish", "aábcdeéfghiíjklmnñoópqrstuúüvwxyz", "áéíóúü"},
+	{"french", "aàâbcçdeéèêëfghiîïjklmnoôpqrstuùûüvwxyÿz", "àâçéèêëîïôùûüÿ"},
+	{"german", "aäbcdefghijklmnoöpqrsßtuüvwxyz", "äöü"},
+	{"greek", "αάβγδεέζηήθιίϊΐκλμνξοόπρστυύϋΰφχψωώ", "άέήίϊΐόύϋΰώ"},
+	{"russian", "абвгдеёжзийклмнопрстуфхцчшщъыьэюя", "ё"},
+}
+
+// English is the alphabet of 'a' through 'z', the alphabet a nil
+// *Alphabet stands for.
+var English = mustAlphabet(NewAlphabet("english", "abcdefghijklmnopqrstuvwxyz", false))
+
+func mustAlphabet(a *Alphabet, err error) *Alphabet {
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Languages lists the names of the built-in alphabets.
+func Languages() []string {
+	var names []string
+	for _, l := range languages {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// LanguageAlphabet returns the built-in alphabet of language, "spanish"
+// say, or if language isn't built in, the alphabet of the alphabet file
+// of that name, see ReadAlphabet. With fold set, the alphabet leaves
+// out the language's accented letters, and Normalize turns them into
+// unaccented letters: "canción" becomes "cancion", but "año" stays
+// "año", since 'ñ' is a letter of Spanish in its own right.
+func LanguageAlphabet(language string, fold bool) (*Alphabet, error) {
+	for _, l := range languages {
+		if l.name == language {
+			return l.alphabet(fold)
+		}
+	}
+	if _, err := os.Stat(language); err == nil {
+		return ReadAlphabet(language, fold)
+	}
+	return nil, fmt.Errorf("unknown language %q, should be one of %s, or an alphabet file", language, strings.Join(Languages(), ", "))
+}
+
+// ReadAlphabet reads an alphabet file, for languages that aren't built
+// in. Lines are a keyword and its value, '#' starts a comment line:
+//
+//	name ukrainian
+//	letters абвгґдеєжзиіїйклмнопрстуфхцчшщьюя
+//	accented
+//
+// "letters" are the alphabet's letters in alphabetical order, more than
+// one "letters" line run together, and spaces between letters don't
+// count. "accented" are the letters that fold leaves out. The name
+// defaults to the file's name.
+func ReadAlphabet(fileName string, fold bool) (*Alphabet, error) {
+	fin, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	l := language{name: strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))}
+	scanner := bufio.NewScanner(fin)
+	lineCounter := 0
+	for scanner.Scan() {
+		lineCounter++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		keyword, value, _ := strings.Cut(line, " ")
+		value = strings.Join(strings.Fields(value), "")
+		switch keyword {
+		case "name":
+			l.name = value
+		case "letters":
+			l.letters += value
+		case "accented":
+			l.accented += value
+		default:
+			return nil, fmt.Errorf("alphabet file %s line %d: unknown keyword %q, should be name, letters or accented", fileName, lineCounter, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("alphabet file %s line %d: %w", fileName, lineCounter, err)
+	}
+	return l.alphabet(fold)
+}
+
+// alphabet makes the Alphabet of language l.
+func (l language) alphabet(fold bool) (*Alphabet, error) {
+	letters := norm.NFC.String(l.letters)
+	accented := norm.NFC.String(l.accented)
+	name := l.name
+	if fold && accented != "" {
+		letters = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(accented, r) {
+				return -1
+			}
+			return r
+		}, letters)
+	}
+	if fold {
+		name += ", folded"
+	}
+	return NewAlphabet(name, letters, fold)
+}
+
+// NewAlphabet makes an alphabet of the lower case letters of letters,
+// in that order. With fold set, Normalize turns accented letters that
+// aren't in the alphabet into ones that are, if taking off the accent
+// gives one.
+func NewAlphabet(name, letters string, fold bool) (*Alphabet, error) {
+	a := &Alphabet{Name: name, fold: fold}
+	seen := make(map[rune]bool)
+	last := '\''
+	for _, r := range norm.NFC.String(letters) {
+		if !unicode.IsLetter(r) || foldCase(r) != r {
+			return nil, fmt.Errorf("alphabet %s: %q isn't a lower case letter", name, r)
+		}
+		if seen[r] {
+			return nil, fmt.Errorf("alphabet %s: letter %c more than once", name, r)
+		}
+		seen[r] = true
+		a.letters = append(a.letters, r)
+		if r > last {
+			last = r
+		}
+	}
+	if len(a.letters) == 0 {
+		return nil, fmt.Errorf("alphabet %s has no letters", name)
+	}
+	if len(a.letters) > maxAlphabetLetters {
+		return nil, fmt.Errorf("alphabet %s has %d letters, more than %d", name, len(a.letters), maxAlphabetLetters)
+	}
+	a.lookup = make([]uint8, last+1)
+	for b, r := range a.letters {
+		a.lookup[r] = uint8(b + 1)
+	}
+	a.lookup['\''] = uint8(len(a.letters) + 1)
+	return a, nil
+}
+
+func (a *Alphabet) orEnglish() *Alphabet {
+	if a == nil {
+		return English
+	}
+	return a
+}
+
+// Len is the number of letters in the alphabet, not counting the apostrophe.
+func (a *Alphabet) Len() int {
+	return len(a.orEnglish().letters)
+}
+
+// Letters returns the letters of the alphabet in alphabetical order.
+func (a *Alphabet) Letters() []rune {
+	return append([]rune(nil), a.orEnglish().letters...)
+}
+
+// Folds reports whether Normalize folds accented letters.
+func (a *Alphabet) Folds() bool {
+	return a.orEnglish().fold
+}
+
+func (a *Alphabet) String() string {
+	return a.orEnglish().Name
+}
+
+// same reports whether a and b have the same letters in the same
+// order, and fold the same way.
+func (a *Alphabet) same(b *Alphabet) bool {
+	a, b = a.orEnglish(), b.orEnglish()
+	return string(a.letters) == string(b.letters) && a.fold == b.fold
+}
+
+// bit finds the bit of letter r in a LetterSet, if it has one.
+func (a *Alphabet) bit(r rune) (uint, bool) {
+	a = a.orEnglish()
+	if r < 0 || int(r) >= len(a.lookup) || a.lookup[r] == 0 {
+		return 0, false
+	}
+	return uint(a.lookup[r] - 1), true
+}
+
+// letter is the letter of bit b of a LetterSet
+func (a *Alphabet) letter(b int) rune {
+	a = a.orEnglish()
+	if b == len(a.letters) {
+		return '\''
+	}
+	return a.letters[b]
+}
+
+// letterNumbers turns the letters of text into their bit numbers,
+// skipping apostrophes and anything else that isn't a letter of a.
+func (a *Alphabet) letterNumbers(text string) []int {
+	var numbers []int
+	for _, r := range text {
+		if b, ok := a.bit(r); ok && r != '\'' {
+			numbers = append(numbers, int(b))
+		}
+	}
+	return numbers
+}
+
+// apostrophe is the LetterSet of just the apostrophe.
+func (a *Alphabet) apostrophe() LetterSet {
+	return 1 << a.Len()
+}
+
+// All is the LetterSet of every letter of the alphabet, without the
+// apostrophe.
+func (a *Alphabet) All() LetterSet {
+	return a.apostrophe() - 1
+}
+
+// Has reports whether letter r is in s.
+func (a *Alphabet) Has(s LetterSet, r rune) bool {
+	b, ok := a.bit(r)
+	return ok && s&(1<<b) != 0
+}
+
+// Add returns s with letter r in it. A LetterSet can't hold letters
+// that aren't in the alphabet, so they get left out.
+func (a *Alphabet) Add(s LetterSet, r rune) LetterSet {
+	if b, ok := a.bit(r); ok {
+		return s | 1<<b
+	}
+	return s
+}
+
+// Remove returns s without letter r.
+func (a *Alphabet) Remove(s LetterSet, r rune) LetterSet {
+	if b, ok := a.bit(r); ok {
+		return s &^ (1 << b)
+	}
+	return s
+}
+
+// Set makes a set of letters, leaving out any not in the alphabet.
+func (a *Alphabet) Set(letters ...rune) LetterSet {
+	var s LetterSet
+	for _, r := range letters {
+		s = a.Add(s, r)
+	}
+	return s
+}
+
+// Only returns the single letter of a set with just one letter in it.
+func (a *Alphabet) Only(s LetterSet) (rune, bool) {
+	if s.Len() != 1 {
+		return 0, false
+	}
+	return a.letter(bits.TrailingZeros64(uint64(s))), true
+}
+
+// Runes returns the letters of s, the apostrophe first, then in
+// alphabetical order. For English, that's the same order as sorting
+// them as runes.
+func (a *Alphabet) Runes(s LetterSet) []rune {
+	letters := make([]rune, 0, s.Len())
+	if s&a.apostrophe() != 0 {
+		letters = append(letters, '\'')
+	}
+	for rest := s &^ a.apostrophe(); rest != 0; rest &= rest - 1 {
+		letters = append(letters, a.letter(bits.TrailingZeros64(uint64(rest))))
+	}
+	return letters
+}
+
+// SetString returns the letters of s as a string, in the order of Runes.
+func (a *Alphabet) SetString(s LetterSet) string {
+	return string(a.Runes(s))
+}
+
+// Contains reports whether every letter of word is a letter of the
+// alphabet, or an apostrophe.
+func (a *Alphabet) Contains(word string) bool {
+	for _, r := range word {
+		if _, ok := a.bit(r); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalize puts text in the alphabet's form: NFC normalized, lower
+// case, see foldCase, and if the alphabet folds, with accented letters
+// the alphabet doesn't have replaced by unaccented ones it does.
+func (a *Alphabet) Normalize(text string) string {
+	a = a.orEnglish()
+	text = strings.Map(foldCase, norm.NFC.String(text))
+	if !a.fold {
+		return text
+	}
+	return strings.Map(a.foldLetter, text)
+}
+
+// foldCase maps every case form of a letter to the same lower case
+// letter. Plain lower casing isn't enough for Greek, where the final
+// sigma 'ς' is the same letter as 'σ', but lower case already.
+func foldCase(r rune) rune {
+	return unicode.ToLower(unicode.ToUpper(r))
+}
+
+// NormalizeLetter is Normalize for a single letter, a hint's clear text
+// letter say.
+func (a *Alphabet) NormalizeLetter(r rune) rune {
+	if n := []rune(a.Normalize(string(r))); len(n) == 1 {
+		return n[0]
+	}
+	return r
+}
+
+// foldLetter takes the accents off letter r, if the alphabet doesn't
+// have r, and does have the unaccented letter.
+func (a *Alphabet) foldLetter(r rune) rune {
+	if _, ok := a.bit(r); ok || !unicode.IsLetter(r) {
+		return r
+	}
+	var base []rune
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			base = append(base, d)
+		}
+	}
+	if len(base) != 1 {
+		return r
+	}
+	if _, ok := a.bit(base[0]); !ok {
+		return r
+	}
+	return base[0]
+}
+
+// sortLetters sorts letters of the alphabet into alphabetical order,
+// anything else after them, in rune order.
+func (a *Alphabet) sortLetters(letters []rune) {
+	sort.Slice(letters, func(i, j int) bool {
+		bi, oki := a.bit(letters[i])
+		bj, okj := a.bit(letters[j])
+		switch {
+		case oki && okj:
+			return bi < bj
+		case oki != okj:
+			return oki
+		}
+		return letters[i] < letters[j]
+	})
+}
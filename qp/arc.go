@@ -0,0 +1,280 @@
+package qp
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"unicode/utf8"
+)
+
+// wordArc is a pair of cipher words that share unsolved cipher letters.
+// A candidate clear text word of from needs some candidate of to with
+// the same clear letters at the shared cipher letters.
+type wordArc struct {
+	from, to       int   // indexes of the cipher words
+	fromPos, toPos []int // positions of the shared cipher letters in each word
+	queued         bool
+}
+
+// revise removes the candidates of from that no candidate of to agrees
+// with, reporting whether it removed any.
+func (a *wordArc) revise(ab *Alphabet, domains [][]string) bool {
+	// Candidates compare by the LetterSet bit numbers of their letters
+	// at the shared positions, packed into a number, small enough to
+	// index an array for 1 or 2 shared letters of English, or for too
+	// many shared letters to pack, a string of bit numbers.
+	width := bits.Len(uint(ab.Len() + 1))
+	packed := len(a.toPos)*width <= 64
+	var small [1 << 10]bool
+	supported := make(map[uint64]bool)
+	supportedStr := make(map[string]bool)
+	var letters []rune
+	var key []byte
+	for _, clear := range domains[a.to] {
+		letters = lettersAt(letters[:0], clear, a.toPos)
+		if !packed {
+			key = bitKey(key[:0], ab, letters)
+			supportedStr[string(key)] = true
+			continue
+		}
+		n := packLetters(ab, letters, width)
+		if n < uint64(len(small)) {
+			small[n] = true
+		} else {
+			supported[n] = true
+		}
+	}
+	var kept []string
+	for _, clear := range domains[a.from] {
+		letters = lettersAt(letters[:0], clear, a.fromPos)
+		if !packed {
+			key = bitKey(key[:0], ab, letters)
+			if supportedStr[string(key)] {
+				kept = append(kept, clear)
+			}
+			continue
+		}
+		n := packLetters(ab, letters, width)
+		if (n < uint64(len(small)) && small[n]) || supported[n] {
+			kept = append(kept, clear)
+		}
+	}
+	if len(kept) == len(domains[a.from]) {
+		return false
+	}
+	domains[a.from] = kept
+	return true
+}
+
+// letterBit is one more than the LetterSet bit number of clear letter r
+// of ab, so it's never 0, except for a letter ab doesn't have. Those all
+// get 0, which only keeps candidates revise might have removed.
+func letterBit(ab *Alphabet, r rune) uint {
+	b, ok := ab.bit(r)
+	if !ok {
+		return 0
+	}
+	return b + 1
+}
+
+// packLetters packs the letterBit numbers of letters into a number,
+// width bits a letter.
+func packLetters(ab *Alphabet, letters []rune, width int) uint64 {
+	var n uint64
+	for _, r := range letters {
+		n = n<<width | uint64(letterBit(ab, r))
+	}
+	return n
+}
+
+// bitKey appends the letterBit numbers of letters to key,
+// a byte each, as a LetterSet has at most 64 letters.
+func bitKey(key []byte, ab *Alphabet, letters []rune) []byte {
+	for _, r := range letters {
+		key = append(key, byte(letterBit(ab, r)))
+	}
+	return key
+}
+
+// lettersAt appends the clear letters of word at positions, which
+// count runes, to letters.
+func lettersAt(letters []rune, word string, positions []int) []rune {
+	for i := 0; i < len(word); i++ {
+		if word[i] >= utf8.RuneSelf {
+			runes := []rune(word)
+			for _, idx := range positions {
+				letters = append(letters, runes[idx])
+			}
+			return letters
+		}
+	}
+	// all single-byte letters
+	for _, idx := range positions {
+		letters = append(letters, rune(word[idx]))
+	}
+	return letters
+}
+
+// arcConsistency prunes the clear text words that each cipher word could
+// be, AC-3 style. A dictionary word stays a candidate of a cipher word
+// only if every other cipher word sharing unsolved cipher letters with
+// it has some candidate with the same clear letters for those cipher
+// letters. Removing a candidate can leave candidates of neighbouring
+// cipher words without support, so it keeps going until nothing changes.
+//
+// It returns a shape dictionary of the words left, and marks the letters
+// of cipher words left with a single candidate. Cipher words that no
+// dictionary word fits any more don't take part, like words missing from
+// the dictionary. If pruning leaves some cipher word without candidates,
+// the puzzle is inconsistent with the solved letters and the dictionary,
+// and arcConsistency returns shapeDict as is, so the cycles can find
+// unmatched words the usual way.
+func arcConsistency(solved *Solved, shapeDict map[string][]string, puzzlewords [][]byte, encodeSelf bool) map[string][]string {
+	w := solved.out()
+	s := newSearch(solved, shapeDict, puzzlewords, encodeSelf, 0)
+
+	domains := make([][]string, len(s.words))
+	var words []int // cipher words with candidates
+	for n := range s.words {
+		domains[n] = s.candidates(n)
+		if len(domains[n]) > 0 {
+			words = append(words, n)
+		}
+	}
+
+	// arcs into each cipher word, for revisiting its neighbours
+	into := make([][]*wordArc, len(s.words))
+	var queue []*wordArc
+	for _, i := range words {
+		for _, j := range words {
+			if i == j {
+				continue
+			}
+			a := sharedLetters(solved, s.words[i], s.words[j])
+			if a == nil {
+				continue
+			}
+			a.from, a.to, a.queued = i, j, true
+			into[j] = append(into[j], a)
+			queue = append(queue, a)
+		}
+	}
+
+	before := make([]int, len(domains))
+	for n := range domains {
+		before[n] = len(domains[n])
+	}
+
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		a.queued = false
+		if !a.revise(solved.Alphabet, domains) {
+			continue
+		}
+		if len(domains[a.from]) == 0 {
+			solved.problem(fmt.Errorf("no candidates of cipher word %q agree with those of %q",
+				string(s.words[a.from]), string(s.words[a.to])))
+			fmt.Fprintf(w, "skipping arc consistency\n")
+			return shapeDict
+		}
+		for _, b := range into[a.from] {
+			if b.from != a.to && !b.queued {
+				b.queued = true
+				queue = append(queue, b)
+			}
+		}
+	}
+
+	// Cipher words of the same shape share a shape dictionary entry,
+	// which keeps the candidates of any of them.
+	keep := make(map[string]map[string]bool)
+	for _, n := range words {
+		if len(domains[n]) < before[n] {
+			fmt.Fprintf(w, "arc consistency left cipher word %q %d of %d candidates\n",
+				string(s.words[n]), len(domains[n]), before[n])
+		}
+		if keep[s.shapes[n]] == nil {
+			keep[s.shapes[n]] = make(map[string]bool)
+		}
+		for _, clear := range domains[n] {
+			keep[s.shapes[n]][clear] = true
+		}
+	}
+	newShapeDict := make(map[string][]string, len(shapeDict))
+	for shape, clearWords := range shapeDict {
+		kept, ok := keep[shape]
+		if !ok {
+			newShapeDict[shape] = clearWords
+			continue
+		}
+		for _, clear := range clearWords {
+			if kept[clear] {
+				newShapeDict[shape] = append(newShapeDict[shape], clear)
+			}
+		}
+	}
+
+	for _, n := range words {
+		if len(domains[n]) != 1 || before[n] == 1 {
+			continue
+		}
+		cipherWord := string(s.words[n])
+		match := domains[n][0]
+		reason := Reason{
+			Kind:  ArcConsistencyReason,
+			Words: []string{cipherWord},
+			Match: match,
+		}
+		snapshot := solved.Snapshot()
+		for idx, p := range []rune(match) {
+			if err := solved.SetSolved(s.words[n][idx], p, reason); err != nil {
+				solved.problem(err)
+				fmt.Fprintf(w, "retracting %q as %q\n", cipherWord, match)
+				solved.Rollback(snapshot)
+				break
+			}
+		}
+	}
+
+	return newShapeDict
+}
+
+// sharedLetters finds the unsolved cipher letters of cipher words from
+// and to, returning nil if they don't share any.
+func sharedLetters(solved *Solved, from, to []rune) *wordArc {
+	fromFirst := firstPositions(from)
+	toFirst := firstPositions(to)
+	var letters []rune
+	for c := range fromFirst {
+		if _, ok := toFirst[c]; !ok {
+			continue
+		}
+		if _, ok := solved.SolvedLetters[c]; ok {
+			// candidates already have the solved letter
+			continue
+		}
+		letters = append(letters, c)
+	}
+	if len(letters) == 0 {
+		return nil
+	}
+	sort.Sort(RuneSlice(letters))
+	a := &wordArc{}
+	for _, c := range letters {
+		a.fromPos = append(a.fromPos, fromFirst[c])
+		a.toPos = append(a.toPos, toFirst[c])
+	}
+	return a
+}
+
+// firstPositions maps each cipher letter of word to its first position.
+func firstPositions(word []rune) map[rune]int {
+	first := make(map[rune]int)
+	for idx, c := range word {
+		if _, ok := first[c]; !ok {
+			first[c] = idx
+		}
+	}
+	return first
+}
@@ -0,0 +1,49 @@
+package qp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReviseNonASCII(t *testing.T) {
+	spanish, err := LanguageAlphabet("spanish", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		from, to       []string
+		fromPos, toPos []int
+		want           []string
+	}{
+		// cipher words xyz and wxvx share x
+		{"año supported by casa", []string{"año", "amo", "ñu"}, []string{"casa"}, []int{0}, []int{1}, []string{"año", "amo"}},
+		{"casa supported by año", []string{"casa", "cosa"}, []string{"año"}, []int{1}, []int{0}, []string{"casa"}},
+		{"ñ at shared positions", []string{"niño", "nino"}, []string{"ñu", "nu"}, []int{2}, []int{0}, []string{"niño", "nino"}},
+		{"only ñ", []string{"niño", "nino"}, []string{"ñu"}, []int{2}, []int{0}, []string{"niño"}},
+	}
+	for _, tt := range tests {
+		domains := [][]string{tt.from, tt.to}
+		a := &wordArc{from: 0, to: 1, fromPos: tt.fromPos, toPos: tt.toPos}
+		a.revise(spanish, domains)
+		if !reflect.DeepEqual(domains[0], tt.want) {
+			t.Errorf("%s: kept %q, want %q", tt.name, domains[0], tt.want)
+		}
+	}
+}
+
+func TestReviseManyShared(t *testing.T) {
+	// 13 shared letters don't pack into a uint64, 5 bits a letter
+	positions := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	domains := [][]string{
+		{"abcdefghijklm", "abcdefghijklz"},
+		{"abcdefghijklm"},
+	}
+	a := &wordArc{from: 0, to: 1, fromPos: positions, toPos: positions}
+	if !a.revise(nil, domains) {
+		t.Fatal("revise removed nothing")
+	}
+	if want := []string{"abcdefghijklm"}; !reflect.DeepEqual(domains[0], want) {
+		t.Errorf("kept %q, want %q", domains[0], want)
+	}
+}
@@ -0,0 +1,211 @@
+package qp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Assistant keeps track of a puzzle that someone solves by hand,
+// one letter at a time, and answers questions about it from the
+// shape dictionary.
+type Assistant struct {
+	Puzzle     *Puzzle
+	solver     *Solver
+	solved     *Solved
+	encodeSelf bool
+	assigned   []int // snapshots from before each of Assign's letters
+}
+
+// NewAssistant starts solving puzzle by hand with the puzzle's hints
+// as the only solved letters.
+func (s *Solver) NewAssistant(puzzle *Puzzle, encodeSelf bool) (*Assistant, error) {
+	if err := puzzle.checkSelfHints(s.Alphabet, encodeSelf); err != nil {
+		return nil, err
+	}
+	a := &Assistant{
+		Puzzle:     puzzle,
+		solver:     s,
+		encodeSelf: encodeSelf,
+		solved: &Solved{
+			SolvedLetters: make(map[rune]rune),
+			CipherLetters: puzzle.CipherLetters,
+			Alphabet:      s.Alphabet,
+		},
+	}
+	for cipherHint, clearHint := range puzzle.Hints {
+		clearHint = s.Alphabet.NormalizeLetter(clearHint)
+		if err := a.solved.SetSolved(cipherHint, clearHint, Reason{Kind: HintReason}); err != nil {
+			return nil, err
+		}
+	}
+	a.solved.exclude(puzzle.NotHints)
+	if err := a.solved.SetSolved('\'', '\'', Reason{Kind: NotEncipheredReason}); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Assign makes clearLetter the solution of cipherLetter, returning
+// an *ErrConflict if either already has a different letter.
+func (a *Assistant) Assign(cipherLetter, clearLetter rune) error {
+	cipherLetter = foldCase(cipherLetter)
+	if !a.inPuzzle(cipherLetter) {
+		return fmt.Errorf("cipher letter %c isn't in the puzzle", cipherLetter)
+	}
+	if !unicode.IsLetter(clearLetter) {
+		return fmt.Errorf("clear letter %c isn't a letter", clearLetter)
+	}
+	clearLetter = a.solved.Alphabet.NormalizeLetter(clearLetter)
+	if !a.encodeSelf && cipherLetter == clearLetter {
+		return fmt.Errorf("cipher letter %c can't encode itself", cipherLetter)
+	}
+	if a.solved.Alphabet.Has(a.solved.Excluded[cipherLetter], clearLetter) {
+		return fmt.Errorf("a hint says cipher letter %c isn't %c", cipherLetter, clearLetter)
+	}
+	snapshot := a.solved.Snapshot()
+	if err := a.solved.SetSolved(cipherLetter, clearLetter, Reason{Kind: AssignedReason}); err != nil {
+		return err
+	}
+	if a.solved.Snapshot() > snapshot {
+		a.assigned = append(a.assigned, snapshot)
+	}
+	return nil
+}
+
+// Undo retracts the most recent of Assign's letters. It reports false
+// if there's nothing left to undo, just the puzzle's hints.
+func (a *Assistant) Undo() (Assignment, bool) {
+	if len(a.assigned) == 0 {
+		return Assignment{}, false
+	}
+	snapshot := a.assigned[len(a.assigned)-1]
+	a.assigned = a.assigned[:len(a.assigned)-1]
+	undone := a.solved.Trail[snapshot]
+	a.solved.Rollback(snapshot)
+	return undone, true
+}
+
+// Key returns the cipher letters solved so far, hints included,
+// with their clear text letters.
+func (a *Assistant) Key() map[rune]rune {
+	key := make(map[rune]rune)
+	for _, cipherLetter := range a.solved.CipherLetters {
+		if clearLetter, ok := a.solved.SolvedLetters[cipherLetter]; ok {
+			key[cipherLetter] = clearLetter
+		}
+	}
+	return key
+}
+
+// Words deciphers the puzzle words with the letters solved so far,
+// '?' for unsolved letters.
+func (a *Assistant) Words() []string {
+	var words []string
+	for _, word := range a.Puzzle.Words {
+		words = append(words, clearWord(word, a.solved.SolvedLetters))
+	}
+	return words
+}
+
+// Complete reports whether every cipher letter has a clear text letter.
+func (a *Assistant) Complete() bool {
+	return a.solved.Complete()
+}
+
+// WordCandidates returns the dictionary words that cipher word could
+// still be, given the letters solved so far. The cipher word doesn't
+// have to be in the puzzle.
+func (a *Assistant) WordCandidates(cipherWord string) []string {
+	cipherWord = strings.Map(foldCase, cipherWord)
+	s := newSearch(a.solved, a.solver.ShapeDict, [][]byte{[]byte(cipherWord)}, a.encodeSelf, 0)
+	return s.candidates(0)
+}
+
+// Candidates returns the alphabetized clear text letters cipherLetter
+// could still be: the letters at its positions in the dictionary words
+// that still fit every puzzle word it appears in.
+func (a *Assistant) Candidates(cipherLetter rune) []rune {
+	cipherLetter = foldCase(cipherLetter)
+	if clearLetter, ok := a.solved.SolvedLetters[cipherLetter]; ok {
+		return []rune{clearLetter}
+	}
+	possible := ^LetterSet(0)
+	s := newSearch(a.solved, a.solver.ShapeDict, a.Puzzle.UniqueWords, a.encodeSelf, 0)
+	for n, word := range s.words {
+		if !containsRune(word, cipherLetter) {
+			continue
+		}
+		var letters LetterSet
+		for _, clear := range s.candidates(n) {
+			for idx, p := range []rune(clear) {
+				if word[idx] == cipherLetter {
+					letters = a.solved.Alphabet.Add(letters, p)
+				}
+			}
+		}
+		possible &= letters
+	}
+	if possible == ^LetterSet(0) {
+		// cipherLetter isn't in any puzzle word
+		return nil
+	}
+	return a.solved.Alphabet.Runes(possible)
+}
+
+// Unfit returns the puzzle's unique cipher words that no dictionary
+// word fits any more, given the letters solved so far.
+func (a *Assistant) Unfit() []string {
+	var unfit []string
+	s := newSearch(a.solved, a.solver.ShapeDict, a.Puzzle.UniqueWords, a.encodeSelf, 0)
+	for n, word := range s.words {
+		if len(s.candidates(n)) == 0 {
+			unfit = append(unfit, string(word))
+		}
+	}
+	sort.Strings(unfit)
+	return unfit
+}
+
+// Hint finds the single most forced letter: the first letter the
+// Solver's cycles deduce, starting from the letters solved so far.
+// It doesn't solve the letter. It reports false if the cycles can't
+// deduce any more letters.
+func (a *Assistant) Hint() (Assignment, bool, error) {
+	puzzle := *a.Puzzle
+	puzzle.Hints = a.Key()
+	result, err := a.solver.Solve(&puzzle, Options{
+		Cycles:     8,
+		EncodeSelf: a.encodeSelf,
+		Out:        io.Discard,
+	})
+	if err != nil {
+		return Assignment{}, false, err
+	}
+	for _, deduction := range result.Deductions {
+		if deduction.Reason.Kind != HintReason {
+			return deduction, true, nil
+		}
+	}
+	return Assignment{}, false, nil
+}
+
+func (a *Assistant) inPuzzle(cipherLetter rune) bool {
+	for _, c := range a.solved.CipherLetters {
+		if c == cipherLetter {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRune(word []rune, r rune) bool {
+	for _, c := range word {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package qp
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Status says how far solving a puzzle got.
+type Status int
+
+const (
+	// FailedStatus is an error, or no letters solved beyond the hints
+	FailedStatus Status = iota
+	// PartlySolvedStatus is some letters solved, but not all
+	PartlySolvedStatus
+	// SolvedStatus is every cipher letter with a clear text letter
+	SolvedStatus
+)
+
+var statusNames = []string{
+	FailedStatus:       "failed",
+	PartlySolvedStatus: "partly solved",
+	SolvedStatus:       "solved",
+}
+
+func (st Status) String() string {
+	if st < 0 || int(st) >= len(statusNames) {
+		return "unknown"
+	}
+	return statusNames[st]
+}
+
+// BatchResult is what Solver.SolveBatch found out about one of its puzzles.
+type BatchResult struct {
+	Puzzle  *Puzzle
+	Result  *Result // nil if Err isn't
+	Err     error
+	Elapsed time.Duration
+}
+
+// Status classifies the BatchResult. Only deductions count as solving
+// letters, not hints or apostrophes.
+func (b *BatchResult) Status() Status {
+	if b.Err != nil || b.Result == nil {
+		return FailedStatus
+	}
+	if len(b.Result.Unsolved) == 0 {
+		return SolvedStatus
+	}
+	for _, d := range b.Result.Deductions {
+		if d.Reason.Kind != HintReason && d.Reason.Kind != NotEncipheredReason {
+			return PartlySolvedStatus
+		}
+	}
+	return FailedStatus
+}
+
+// Accuracy counts the cipher letters of a puzzle with a known solution
+// by how solving them went. Hints and apostrophes don't count.
+type Accuracy struct {
+	Right    int // solved, same as the known solution
+	Wrong    int // solved, different from the known solution
+	Unsolved int // no clear text letter
+}
+
+// Letters is the number of cipher letters counted.
+func (a Accuracy) Letters() int {
+	return a.Right + a.Wrong + a.Unsolved
+}
+
+// Add returns the sums of the counts of a and b.
+func (a Accuracy) Add(b Accuracy) Accuracy {
+	return Accuracy{
+		Right:    a.Right + b.Right,
+		Wrong:    a.Wrong + b.Wrong,
+		Unsolved: a.Unsolved + b.Unsolved,
+	}
+}
+
+// Accuracy compares the solved letters with the puzzle's known solution.
+// It reports false if the puzzle has no known solution. Cipher letters
+// the known solution leaves out don't count.
+func (b *BatchResult) Accuracy() (Accuracy, bool) {
+	var a Accuracy
+	if len(b.Puzzle.Known) == 0 {
+		return a, false
+	}
+	for _, cipherLetter := range b.Puzzle.CipherLetters {
+		if _, ok := b.Puzzle.Hints[cipherLetter]; ok || !unicode.IsLetter(cipherLetter) {
+			continue
+		}
+		known, ok := b.Puzzle.Known[cipherLetter]
+		if !ok {
+			continue
+		}
+		var clearLetter rune
+		if b.Result != nil {
+			clearLetter, ok = b.Result.Key[cipherLetter]
+		}
+		switch {
+		case b.Result == nil || !ok:
+			a.Unsolved++
+		case clearLetter == known:
+			a.Right++
+		default:
+			a.Wrong++
+		}
+	}
+	return a, true
+}
+
+// SolveBatch solves puzzles with up to workers of them at a time,
+// returning their BatchResults in the same order as puzzles. The
+// progress output of puzzles solved at the same time would interleave,
+// so it ignores opts.Out and opts.Verbose. It stops starting puzzles
+// once ctx is done.
+func (s *Solver) SolveBatch(ctx context.Context, puzzles []*Puzzle, opts Options, workers int) []*BatchResult {
+	opts.Out = io.Discard
+	opts.Verbose = false
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]*BatchResult, len(puzzles))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range next {
+				start := time.Now()
+				result, err := s.SolveContext(ctx, puzzles[n], opts)
+				results[n] = &BatchResult{
+					Puzzle:  puzzles[n],
+					Result:  result,
+					Err:     err,
+					Elapsed: time.Since(start),
+				}
+			}
+		}()
+	}
+
+	for n := range puzzles {
+		if ctx.Err() != nil {
+			results[n] = &BatchResult{Puzzle: puzzles[n], Err: ctx.Err()}
+			continue
+		}
+		next <- n
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
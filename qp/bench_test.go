@@ -0,0 +1,83 @@
+package qp
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// paddingWords makes n random strings of lower case letters, 2 to 12
+// letters long, the same ones every time. They aren't words, but they
+// give the shapes of a puzzle's words as many dictionary entries as a
+// dictionary of a few thousand real words would, which is what the
+// solver's time goes on.
+func paddingWords(n int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	words := make([]string, n)
+	for i := range words {
+		word := make([]byte, 2+rnd.Intn(11))
+		for j := range word {
+			word[j] = byte('a' + rnd.Intn(26))
+		}
+		words[i] = string(word)
+	}
+	return words
+}
+
+// benchDictionary reads testdata/words, real words, plus 7,200 padding
+// words, see paddingWords.
+func benchDictionary(b *testing.B) *Dictionary {
+	b.Helper()
+	real, err := os.ReadFile(filepath.Join("testdata", "words"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	dictName := filepath.Join(b.TempDir(), "words")
+	text := string(real) + strings.Join(paddingWords(7200), "\n") + "\n"
+	if err := os.WriteFile(dictName, []byte(text), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	dict, err := ReadDictionary(dictName, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return dict
+}
+
+func BenchmarkNewRunesDict(b *testing.B) {
+	dict := benchDictionary(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewRunesDict(dict.Shapes)
+	}
+}
+
+func BenchmarkSolve(b *testing.B) {
+	dict := benchDictionary(b)
+	solver := &Solver{ShapeDict: dict.Shapes, Counts: dict.Counts}
+	opts := Options{
+		Cycles:    8,
+		Backtrack: true,
+		Out:       io.Discard,
+	}
+	for _, name := range []string{"snakes.in", "ambig.in", "miss.in"} {
+		puzzles, err := ReadPuzzles(filepath.Join("testdata", name))
+		if err != nil {
+			b.Fatal(err)
+		}
+		puzzle := puzzles[0]
+		if _, err := solver.Solve(puzzle, opts); err != nil {
+			b.Fatalf("%s: %v", name, err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				solver.Solve(puzzle, opts)
+			}
+		})
+	}
+}
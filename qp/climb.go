@@ -0,0 +1,243 @@
+package qp
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"unicode"
+)
+
+// climber holds the state of hill climbing over keys for a puzzle
+// without word breaks, a Patristocrat.
+type climber struct {
+	model         *NgramModel
+	ab            *Alphabet // the model's clear text letters
+	cipherLetters []rune    // letters of the cipher text, position is the letter's number
+	text          []int     // cipher text as cipher letter numbers
+	fixed         []bool    // cipher letter numbers with a hint
+	excluded      [][]int   // clear text letter numbers each cipher letter number isn't
+	encodeSelf    bool
+	rnd           *rand.Rand
+}
+
+// newClimber numbers the cipher letters of puzzle, and turns its words
+// into one long sequence of cipher letter numbers.
+func newClimber(model *NgramModel, puzzle *Puzzle, opts Options) (*climber, error) {
+	c := &climber{
+		model:      model,
+		ab:         model.Alphabet,
+		encodeSelf: opts.EncodeSelf,
+		rnd:        rand.New(rand.NewSource(opts.Seed)),
+	}
+	number := make(map[rune]int)
+	for _, cipherLetter := range puzzle.CipherLetters {
+		if !unicode.IsLetter(cipherLetter) {
+			continue
+		}
+		number[cipherLetter] = len(c.cipherLetters)
+		c.cipherLetters = append(c.cipherLetters, cipherLetter)
+	}
+	if len(c.cipherLetters) > c.ab.Len() {
+		return nil, fmt.Errorf("%d cipher letters, more than %d clear text letters", len(c.cipherLetters), c.ab.Len())
+	}
+	for _, word := range puzzle.Words {
+		for _, r := range string(word) {
+			if n, ok := number[r]; ok {
+				c.text = append(c.text, n)
+			}
+		}
+	}
+	c.excluded = make([][]int, len(c.cipherLetters))
+	for cipherLetter, clearLetters := range puzzle.NotHints {
+		n, ok := number[cipherLetter]
+		if !ok {
+			continue
+		}
+		for _, clearLetter := range clearLetters {
+			c.excluded[n] = append(c.excluded[n], c.ab.letterNumbers(string(c.ab.NormalizeLetter(clearLetter)))...)
+		}
+	}
+	// A hint that allowed rules out would leave randomKey nothing to make.
+	c.fixed = make([]bool, c.ab.Len())
+	for cipherHint, clearHint := range puzzle.Hints {
+		n, ok := number[cipherHint]
+		clearHint = c.ab.NormalizeLetter(clearHint)
+		hint := c.ab.letterNumbers(string(clearHint))
+		if !ok || len(hint) != 1 {
+			return nil, fmt.Errorf("hint %c = %c doesn't fit the puzzle", cipherHint, clearHint)
+		}
+		if !c.encodeSelf && clearHint == cipherHint {
+			return nil, fmt.Errorf("hint %c = %c, but letters can't encode themselves", cipherHint, clearHint)
+		}
+		for _, excluded := range c.excluded[n] {
+			if excluded == hint[0] {
+				return nil, fmt.Errorf("hint %c = %c contradicts hint %c != %c", cipherHint, clearHint, cipherHint, clearHint)
+			}
+		}
+		c.fixed[n] = true
+	}
+	return c, nil
+}
+
+// maxKeyAttempts is how many random keys randomKey tries for one
+// that allowed lets through, before deciding the hints leave none.
+const maxKeyAttempts = 100000
+
+// randomKey makes a key, a permutation of the clear text letter
+// numbers, 0 through 25 for English. Cipher letter number i deciphers as clear text letter key[i].
+// Hinted cipher letters get their hint, and if cipher letters can't
+// encode themselves, none does. It gives up with ctx's error when ctx
+// is done, or an error after maxKeyAttempts keys that aren't allowed.
+func (c *climber) randomKey(ctx context.Context, hints map[rune]rune) ([]int, error) {
+	key := make([]int, c.ab.Len())
+	for attempt := 0; attempt < maxKeyAttempts; attempt++ {
+		if attempt%1000 == 999 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		for i, p := range c.rnd.Perm(len(key)) {
+			key[i] = p
+		}
+		for n, cipherLetter := range c.cipherLetters {
+			if clearHint, ok := hints[cipherLetter]; ok {
+				// swap the hint's clear letter into place
+				hint := c.ab.letterNumbers(string(c.ab.NormalizeLetter(clearHint)))[0]
+				for j := range key {
+					if key[j] == hint {
+						key[j], key[n] = key[n], key[j]
+						break
+					}
+				}
+			}
+		}
+		if c.allowed(key) {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no random key in %d tries fits the hints", maxKeyAttempts)
+}
+
+// allowed reports whether key has no cipher letter encoding itself,
+// or doesn't have to care, and no cipher letter with a clear text letter
+// that a "x!=e" hint rules out.
+func (c *climber) allowed(key []int) bool {
+	for n, cipherLetter := range c.cipherLetters {
+		if !c.encodeSelf && c.ab.letter(key[n]) == cipherLetter {
+			return false
+		}
+		for _, excluded := range c.excluded[n] {
+			if key[n] == excluded {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *climber) score(key []int, plain []int) float64 {
+	for i, n := range c.text {
+		plain[i] = key[n]
+	}
+	return c.model.score(plain)
+}
+
+// climb swaps pairs of clear text letters in key, keeping any swap that
+// improves the n-gram score, until no swap improves it. It returns the
+// final score.
+func (c *climber) climb(key []int) float64 {
+	plain := make([]int, len(c.text))
+	best := c.score(key, plain)
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(key); i++ {
+			for j := i + 1; j < len(key); j++ {
+				if c.fixed[i] || c.fixed[j] {
+					continue
+				}
+				key[i], key[j] = key[j], key[i]
+				if c.allowed(key) {
+					if s := c.score(key, plain); s > best {
+						best = s
+						improved = true
+						continue
+					}
+				}
+				key[i], key[j] = key[j], key[i]
+			}
+		}
+	}
+	return best
+}
+
+// solveNgrams ignores word shapes, and hill climbs from opts.Restarts
+// random keys, keeping the key whose clear text has the highest n-gram
+// score. Solver.Ngrams has to have a model.
+func (s *Solver) solveNgrams(ctx context.Context, puzzle *Puzzle, opts Options) (*Result, error) {
+	w := opts.Out
+	if s.Ngrams == nil {
+		return nil, fmt.Errorf("n-gram strategy needs an n-gram model")
+	}
+	c, err := newClimber(s.Ngrams, puzzle, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var bestKey []int
+	bestScore := 0.0
+	restarts := opts.Restarts
+	if restarts < 1 {
+		restarts = 1
+	}
+	for restart := 0; restart < restarts; restart++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("restart %d: %w", restart, err)
+		}
+		key, err := c.randomKey(ctx, puzzle.Hints)
+		if err != nil {
+			return nil, fmt.Errorf("restart %d: %w", restart, err)
+		}
+		score := c.climb(key)
+		fmt.Fprintf(w, "restart %d %d-gram score %.2f\n", restart, s.Ngrams.N, score)
+		if bestKey == nil || score > bestScore {
+			bestKey, bestScore = key, score
+			if opts.Verbose {
+				fmt.Fprintf(w, "\tnew best key: %s\n", string(c.clearLetters(bestKey)))
+			}
+		}
+	}
+
+	solved := &Solved{
+		SolvedLetters: make(map[rune]rune),
+		CipherLetters: puzzle.CipherLetters,
+		Alphabet:      c.ab,
+		Out:           w,
+	}
+	clearLetters := c.clearLetters(bestKey)
+	for n, cipherLetter := range c.cipherLetters {
+		solved.mark(cipherLetter, clearLetters[n], Reason{Kind: HillClimbReason, N: s.Ngrams.N})
+	}
+	for _, cipherLetter := range puzzle.CipherLetters {
+		if !unicode.IsLetter(cipherLetter) {
+			// apostrophes and such are never enciphered
+			solved.mark(cipherLetter, cipherLetter, Reason{Kind: NotEncipheredReason})
+		}
+	}
+
+	fmt.Fprintf(w, "best %d-gram score %.2f\n", s.Ngrams.N, bestScore)
+	printSolvedLetters(solved)
+	fmt.Fprintln(w, "\nSolved Puzzle:")
+	printSolvedWords(w, puzzle.Words, solved)
+
+	return newResult(puzzle, solved, restarts, nil), nil
+}
+
+// clearLetters turns a key's numbers into clear text letters, in the
+// order of the cipher letters.
+func (c *climber) clearLetters(key []int) []rune {
+	letters := make([]rune, len(c.cipherLetters))
+	for n := range c.cipherLetters {
+		letters[n] = c.ab.letter(key[n])
+	}
+	return letters
+}
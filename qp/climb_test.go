@@ -0,0 +1,56 @@
+package qp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewClimberRejectsHints(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		encodeSelf bool
+		wantErr    bool
+	}{
+		{"hint", "qxf abc\nq=t\n", false, false},
+		{"self-encoding hint", "qxf abc\nq=q\n", false, true},
+		{"self-encoding hint, encode self", "qxf abc\nq=q\n", true, false},
+	}
+	model := &NgramModel{N: 3}
+	for _, tt := range tests {
+		puzzle, err := ParsePuzzle([]byte(tt.text))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		_, err = newClimber(model, puzzle, Options{EncodeSelf: tt.encodeSelf})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want an error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRandomKeyGivesUp(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("qxf abc\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// q can't be any letter at all
+	for _, l := range English.Letters() {
+		if err := puzzle.AddNotHint('q', l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := newClimber(&NgramModel{N: 3}, puzzle, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.randomKey(context.Background(), puzzle.Hints); err == nil {
+		t.Error("randomKey made a key no letter allows")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.randomKey(ctx, puzzle.Hints); err != context.Canceled {
+		t.Errorf("randomKey with a canceled context: %v", err)
+	}
+}
@@ -1,42 +1,68 @@
 package qp
 
-import "unicode"
+import (
+	"unicode"
+	"unicode/utf8"
+)
 
+// shapeSymbols stand for the 1st, 2nd, 3rd... different letters of a
+// word in its configuration. Single-quote isn't one of them, so only
+// single-quotes that appear in input words appear in the "shape" of
+// those words.
+const shapeSymbols = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// shapeSymbol returns the symbol for the n'th different letter of a word,
+// counting from 0. Past the 62 of shapeSymbols, which no real word gets
+// to, it keeps going from U+0100, so every letter still gets its own.
+func shapeSymbol(n int) rune {
+	if n < len(shapeSymbols) {
+		return rune(shapeSymbols[n])
+	}
+	return 0x100 + rune(n-len(shapeSymbols))
+}
+
+// StringConfiguration returns the "shape" of line: the letters of line
+// replaced by a symbol for each different letter, in order of first
+// appearance, ignoring case, so "peen" is "0112". Single-quotes stay,
+// anything else gets left out. Each letter of line, whatever its rune,
+// is a single rune of the configuration.
 func StringConfiguration(line string) string {
 
 	if len(line) == 0 {
 		return ""
 	}
 
-	key := make([]rune, len(line))
-	idx := 0
+	key := make([]rune, 0, utf8.RuneCountInString(line))
 
-	var scorecard [255]rune
-
-	i := 0
+	// different letters in order of first appearance, the index of
+	// a letter is the number of its symbol. Words are short enough
+	// that looking through them beats a map.
+	var scorecard [32]rune
+	seen := scorecard[:0]
 
 	for _, r := range line {
-		l := unicode.ToLower(r)
+		l := foldCase(r)
 		if unicode.IsLetter(l) {
-			if previous := scorecard[int(l)]; previous != 0 {
-				key[idx] = previous
-			} else {
-				// single-quote appears before '0' in Unicode,
-				// so only single-quotes that appear in input words
-				// appear in "shape" of those words
-				scorecard[l] = '0' + rune(i)
-				i++
-				key[idx] = scorecard[l]
+			n := 0
+			for n < len(seen) && seen[n] != l {
+				n++
+			}
+			if n == len(seen) {
+				seen = append(seen, l)
 			}
-			idx++
+			key = append(key, shapeSymbol(n))
 			continue
 		}
 		if l == '\'' {
-			key[idx] = l
-			idx++
+			key = append(key, l)
 			continue
 		}
 	}
 
-	return string(key[:idx])
+	return string(key)
+}
+
+// shapeLength returns the number of letters a configuration stands for.
+func shapeLength(configuration string) int {
+	return utf8.RuneCountInString(configuration)
 }
@@ -0,0 +1,116 @@
+package qp
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStringConfiguration(t *testing.T) {
+	// 72 different letters, more than the 62 shapeSymbols
+	many := "abcdefghijklmnopqrstuvwxyz" + "αβγδεζηθικλμνξοπρστυφχψω" + "бвгдежзийклмнпрстуфхцч"
+	tests := []struct {
+		word, want string
+	}{
+		{"", ""},
+		{"peen", "0112"},
+		{"PeEn", "0112"},
+		{"didn't", "0102'3"},
+		{"'tis", "'012"},
+		{"o'clock", "0'12013"},
+		{"gratefulness", "012345678499"},
+		{"uncopyrightable", "0123456789abcde"},
+		{"añoaño", "012012"},
+		{"Άλφα", "0123"},
+		{"καλημέρα", "01234561"},
+		{"сосна", "01023"},
+		{"snake's-eye", "01234'0454"},
+		{many, shapeSymbols + "ĀāĂăĄąĆćĈĉ"},
+		{many + "a", shapeSymbols + "ĀāĂăĄąĆćĈĉ" + "0"},
+	}
+	for _, tt := range tests {
+		got := StringConfiguration(tt.word)
+		if got != tt.want {
+			t.Errorf("StringConfiguration(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(tt.want); shapeLength(got) != n {
+			t.Errorf("shapeLength(%q) = %d, want %d", got, shapeLength(got), n)
+		}
+	}
+}
+
+// letterPattern is the pattern StringConfiguration encodes: the number
+// of each letter of word in order of first appearance, -1 for quotes.
+func letterPattern(word string) []int {
+	var seen []rune
+	var pattern []int
+	for _, r := range word {
+		if r == '\'' {
+			pattern = append(pattern, -1)
+			continue
+		}
+		l := foldCase(r)
+		n := 0
+		for n < len(seen) && seen[n] != l {
+			n++
+		}
+		if n == len(seen) {
+			seen = append(seen, l)
+		}
+		pattern = append(pattern, n)
+	}
+	return pattern
+}
+
+// Words with different letter patterns never share a shape, and words
+// with the same pattern always do.
+func TestStringConfigurationDistinct(t *testing.T) {
+	letters := []rune("abcdefghijklmnopqrstuvwxyzñéüαβγδεζηθкдж'")
+	rnd := rand.New(rand.NewSource(1))
+	var words []string
+	for i := 0; i < 3000; i++ {
+		var word strings.Builder
+		// mostly short words, with a few long enough to run past
+		// the 62 shapeSymbols
+		length := 1 + rnd.Intn(8)
+		if i%50 == 0 {
+			length = 60 + rnd.Intn(40)
+		}
+		// a few letters make for repeated patterns, many for long ones
+		n := 2 + rnd.Intn(len(letters)-2)
+		for j := 0; j < length; j++ {
+			word.WriteRune(letters[rnd.Intn(n)])
+		}
+		words = append(words, word.String())
+	}
+	byShape := make(map[string]string)
+	for _, word := range words {
+		shape := StringConfiguration(word)
+		other, ok := byShape[shape]
+		if !ok {
+			byShape[shape] = word
+			continue
+		}
+		if !reflect.DeepEqual(letterPattern(word), letterPattern(other)) {
+			t.Errorf("%q and %q have different letter patterns, the same shape %q", word, other, shape)
+		}
+	}
+	byPattern := make(map[string]string)
+	for _, word := range words {
+		pattern := fmt.Sprint(letterPattern(word))
+		other, ok := byPattern[pattern]
+		if !ok {
+			byPattern[pattern] = word
+			continue
+		}
+		if StringConfiguration(word) != StringConfiguration(other) {
+			t.Errorf("%q and %q have the same letter pattern, different shapes", word, other)
+		}
+	}
+	if len(byShape) == len(words) {
+		t.Error("no two words share a shape, nothing got compared")
+	}
+}
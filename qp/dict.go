@@ -2,11 +2,11 @@ package qp
 
 import (
 	"bufio"
-	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-
-	"golang.org/x/text/unicode/norm"
+	"unicode/utf8"
 )
 
 // Entry represents the letters of the words having a particular configuration
@@ -14,23 +14,70 @@ import (
 // 15 words in /usr/share/dict/words.
 type Entry struct {
 	Length int
-	Runes  map[int]map[rune]bool
+	Runes  []LetterSet
 	// Runes[0] are all the dictionary words' first letters.
-	// the first letters are keys in the map-that-is-the-value
 	// Runes[1] are all the dictionary words with this shape 2nd letters,
 	// Runes[2] are the 3rd letters from dictionary words with this shape, etc
 }
 
-// NewShapeDict composes a map keyed by configuration. values are
-// slices of strings, each string has that configuration
-func NewShapeDict(fileName string) (map[string][]string, error) {
+// Dictionary holds the words of a clear text dictionary file
+type Dictionary struct {
+	// Shapes has slices of words keyed by configuration. If the
+	// file has word counts, the more frequent words come first.
+	Shapes map[string][]string
+	// Counts has the number of times each word appears in some
+	// body of text, empty if the dictionary file doesn't have counts.
+	Counts map[string]int
+	// Letters has the per-position letters of each shape, as NewRunesDict
+	// makes them. It's nil until RunesDict fills it in.
+	Letters map[string]*Entry
+	// IndexFile is the shape-index file the Dictionary came from, if any,
+	// see LoadDictionary.
+	IndexFile string
+	// Alphabet is the letters of the dictionary's language. Words
+	// with other letters get left out.
+	Alphabet *Alphabet
+	// IndexError is why LoadDictionary didn't use the dictionary's
+	// shape-index file, a broken or out of date one say. It's nil if
+	// LoadDictionary used it, or there isn't one.
+	IndexError error
+
+	indexLetters map[string][]byte // undecoded Letters from IndexFile
+}
+
+// RunesDict returns the per-position letters of each shape,
+// composing them the first time.
+func (d *Dictionary) RunesDict() map[string]*Entry {
+	if d.Letters != nil {
+		return d.Letters
+	}
+	if d.indexLetters == nil {
+		d.Letters = d.Alphabet.RunesDict(d.Shapes)
+		return d.Letters
+	}
+	d.Letters = make(map[string]*Entry)
+	for shape, letters := range d.indexLetters {
+		d.Letters[shape] = indexEntry(shape, letters)
+	}
+	d.indexLetters = nil
+	return d.Letters
+}
+
+// ReadDictionary reads a clear text dictionary file, one word per line,
+// of the letters of alphabet ab, English if ab is nil. A line can have a
+// word count after the word, separated by a tab: word<TAB>count
+func ReadDictionary(fileName string, ab *Alphabet) (*Dictionary, error) {
 	fin, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
 	}
 	defer fin.Close()
 
-	d := make(map[string][]string)
+	d := &Dictionary{
+		Shapes:   make(map[string][]string),
+		Counts:   make(map[string]int),
+		Alphabet: ab.orEnglish(),
+	}
 
 	scanner := bufio.NewScanner(fin)
 
@@ -38,62 +85,83 @@ func NewShapeDict(fileName string) (map[string][]string, error) {
 
 	for scanner.Scan() {
 		lineCounter++
-		line := scanner.Text()
-		line = strings.ToLower(line)
-		line = norm.NFC.String(line)
+		line := d.Alphabet.Normalize(scanner.Text())
+
+		if word, count, found := strings.Cut(line, "\t"); found {
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil {
+				return nil, &ErrDictionaryRead{FileName: fileName, Line: lineCounter, Err: err}
+			}
+			line = word
+			d.Counts[line] += n
+		}
 
 		config := StringConfiguration(line)
-		if len(config) != len(line) {
+		if shapeLength(config) != utf8.RuneCountInString(line) {
+			// something besides letters and apostrophes
+			continue
+		}
+		if !d.Alphabet.Contains(line) {
+			// "café" in an English dictionary, say
 			continue
 		}
-		d[config] = append(d[config], line)
+		d.Shapes[config] = append(d.Shapes[config], line)
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Fatalf("problem line %d: %v", lineCounter, err)
+		return nil, &ErrDictionaryRead{FileName: fileName, Line: lineCounter, Err: err}
+	}
+
+	if len(d.Counts) > 0 {
+		for _, words := range d.Shapes {
+			sort.SliceStable(words, func(i, j int) bool {
+				return d.Counts[words[i]] > d.Counts[words[j]]
+			})
+		}
 	}
 
 	return d, nil
 }
 
-// NewRunesDict accepts a map of []strings, keyed by shape/configuration.
-// It returns a map of struct Entry, which are the shape's possible letters
-// at each index.
+// NewShapeDict composes a map keyed by configuration. values are
+// slices of strings, each string has that configuration
+func NewShapeDict(fileName string) (map[string][]string, error) {
+	d, err := ReadDictionary(fileName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return d.Shapes, nil
+}
+
+// NewRunesDict accepts a map of []strings of English words, keyed by
+// shape/configuration. It returns a map of struct Entry, which are the
+// shape's possible letters at each index.
 func NewRunesDict(wordDict map[string][]string) map[string]*Entry {
+	return English.RunesDict(wordDict)
+}
+
+// RunesDict is NewRunesDict for words of alphabet a.
+func (a *Alphabet) RunesDict(wordDict map[string][]string) map[string]*Entry {
 	d := make(map[string]*Entry)
 
 	for configuration, words := range wordDict {
-		if entry, ok := d[configuration]; ok {
-			// encountered this configuration before
-			for _, word := range words {
-				for idx, r := range word {
-					if r != '\'' && (r > 'z' || r < 'a') {
-						continue
-					}
-					if found := entry.Runes[idx][r]; !found {
-						// haven't seen this letter at this position
-						entry.Runes[idx][r] = true
-					}
-				}
-			}
-		} else {
-			// new to us configuration
-			var e Entry
-			e.Length = len(configuration)
-			e.Runes = make(map[int]map[rune]bool)
-			for _, word := range words {
-				for idx, r := range word {
-					if (r > 'z' || r < 'a') && r != '\'' {
-						continue
-					}
-					if _, ok := e.Runes[idx]; !ok {
-						e.Runes[idx] = make(map[rune]bool)
-					}
-					e.Runes[idx][r] = true
+		length := shapeLength(configuration)
+		e := &Entry{
+			Length: length,
+			Runes:  make([]LetterSet, length),
+		}
+		for _, word := range words {
+			idx := 0
+			for _, r := range word {
+				if idx >= len(e.Runes) {
+					break
 				}
+				// Add leaves out anything but the alphabet's letters and apostrophes
+				e.Runes[idx] = a.Add(e.Runes[idx], r)
+				idx++
 			}
-			d[configuration] = &e
 		}
+		d[configuration] = e
 	}
 
 	return d
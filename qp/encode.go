@@ -0,0 +1,41 @@
+package qp
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// RandomKey makes a mono-alphabetic cipher key, clear text letters
+// of alphabet ab, English if ab is nil, to cipher letters of the same
+// alphabet.
+func RandomKey(ab *Alphabet, rnd *rand.Rand) map[rune]rune {
+	letters := ab.Letters()
+	key := make(map[rune]rune)
+	for i, p := range rnd.Perm(len(letters)) {
+		key[letters[i]] = letters[p]
+	}
+	return key
+}
+
+// Encipher replaces the letters of alphabet ab in clearText with their
+// cipher letters from key, after putting clearText in the alphabet's
+// form, lower case letters for upper case letters say. It leaves
+// everything else alone. It also returns the clear text letters that
+// appear in clearText, in alphabetical order.
+func Encipher(ab *Alphabet, clearText string, key map[rune]rune) (string, []rune) {
+	var cipherText strings.Builder
+	clearLetters := make(map[rune]bool)
+	for _, c := range ab.Normalize(clearText) {
+		if _, ok := ab.bit(c); ok && c != '\'' {
+			clearLetters[c] = true
+			c = key[c]
+		}
+		cipherText.WriteRune(c)
+	}
+	var letters []rune
+	for l := range clearLetters {
+		letters = append(letters, l)
+	}
+	ab.sortLetters(letters)
+	return cipherText.String(), letters
+}
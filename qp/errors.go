@@ -0,0 +1,57 @@
+package qp
+
+import "fmt"
+
+// ErrNoCandidates is the error when every clear text letter that might
+// solve a cipher letter is already the solution of some other cipher letter.
+type ErrNoCandidates struct {
+	CipherLetter rune
+	Candidates   []rune // alphabetized, exhausted clear text letters
+}
+
+func (e *ErrNoCandidates) Error() string {
+	return fmt.Sprintf("cipher letter %c has no possible matches, candidates %q all solved",
+		e.CipherLetter, string(e.Candidates),
+	)
+}
+
+// newErrNoCandidates composes an ErrNoCandidates from a set of clear letters
+func newErrNoCandidates(ab *Alphabet, cipherLetter rune, m LetterSet) *ErrNoCandidates {
+	return &ErrNoCandidates{CipherLetter: cipherLetter, Candidates: ab.Runes(m)}
+}
+
+// ErrDictionaryRead is the error when a clear text dictionary
+// can't be read all the way through.
+type ErrDictionaryRead struct {
+	FileName string
+	Line     int // last line read successfully
+	Err      error
+}
+
+func (e *ErrDictionaryRead) Error() string {
+	return fmt.Sprintf("reading dictionary %s, problem line %d: %v", e.FileName, e.Line, e.Err)
+}
+
+func (e *ErrDictionaryRead) Unwrap() error {
+	return e.Err
+}
+
+// ErrPuzzleParse is the error when a line of a puzzle file doesn't make
+// sense, a malformed hint, or one that contradicts another hint, say.
+type ErrPuzzleParse struct {
+	FileName string // empty for puzzle text that didn't come from a file
+	Line     int
+	Text     string // the line itself
+	Err      error
+}
+
+func (e *ErrPuzzleParse) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("puzzle line %d %q: %v", e.Line, e.Text, e.Err)
+	}
+	return fmt.Sprintf("puzzle file %s line %d %q: %v", e.FileName, e.Line, e.Text, e.Err)
+}
+
+func (e *ErrPuzzleParse) Unwrap() error {
+	return e.Err
+}
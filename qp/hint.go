@@ -0,0 +1,261 @@
+package qp
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// hint is a single hint of a hint line, cipher letter = clear text
+// letter, or with not set, cipher letter != clear text letter.
+type hint struct {
+	cipherLetter, clearLetter rune
+	not                       bool
+}
+
+// isHintLine reports whether a line of a puzzle file is hints rather
+// than cipher text. Cipher text never has '=', "!=" or '≠' in it, but
+// it can have the words "equals", "does not equal" or "doesn't equal",
+// so those only make a hint between single letters, "If X equals G".
+func isHintLine(line string) bool {
+	tokens := hintTokens(line)
+	for i, token := range tokens {
+		switch token {
+		case "=", "≠":
+			return true
+		}
+		if _, err := hintLetter(token); err != nil {
+			continue
+		}
+		if _, n := hintOperator(tokens[i+1:]); n > 0 && i+1+n < len(tokens) {
+			if _, err := hintLetter(tokens[i+1+n]); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hintOperators are the ways of saying a cipher letter is, or isn't,
+// a clear text letter, longest first. Each is a sequence of tokens.
+var hintOperators = []struct {
+	tokens []string
+	not    bool
+}{
+	{[]string{"does", "not", "equal"}, true},
+	{[]string{"doesn't", "equal"}, true},
+	{[]string{"≠"}, true}, // "!=" too, see hintTokens
+	{[]string{"equals"}, false},
+	{[]string{"="}, false},
+}
+
+// parseHints picks apart a hint line. A hint line has one or more hints,
+// separated by commas, semicolons or "and":
+//
+//	x=g
+//	A=b, C=d
+//	If X equals G
+//	X!=e and Y does not equal q
+//
+// Hints that aren't a single cipher letter, an operator and a single
+// clear text letter are errors, "x=g=h" or "=q" say, and so is a
+// separator without a hint after it, "x=g and".
+func parseHints(line string) ([]hint, error) {
+	tokens := hintTokens(line)
+	var hints []hint
+	for {
+		for len(tokens) > 0 && tokens[0] == "if" {
+			tokens = tokens[1:]
+		}
+		if len(tokens) == 0 {
+			if len(hints) == 0 {
+				return nil, fmt.Errorf("no hints in %q", line)
+			}
+			return nil, fmt.Errorf("no hint after the last %q", lastSeparator(hintTokens(line)))
+		}
+		var h hint
+		var err error
+		h, tokens, err = parseHint(tokens)
+		if err != nil {
+			return nil, err
+		}
+		hints = append(hints, h)
+		if len(tokens) == 0 {
+			return hints, nil
+		}
+		// parseHint leaves a separator, maybe more than one, ", and"
+		for len(tokens) > 0 && isHintSeparator(tokens[0]) {
+			tokens = tokens[1:]
+		}
+	}
+}
+
+// hintOperator matches the hint operator at the start of tokens, if
+// any, returning whether it's a "!=" operator and its number of tokens,
+// 0 if there's no operator.
+func hintOperator(tokens []string) (bool, int) {
+	for _, op := range hintOperators {
+		if len(tokens) >= len(op.tokens) && strings.Join(tokens[:len(op.tokens)], " ") == strings.Join(op.tokens, " ") {
+			return op.not, len(op.tokens)
+		}
+	}
+	return false, 0
+}
+
+func isHintSeparator(token string) bool {
+	return token == "," || token == ";" || token == "and"
+}
+
+// lastSeparator finds the separator at the end of a hint line's tokens.
+func lastSeparator(tokens []string) string {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if isHintSeparator(tokens[i]) {
+			return tokens[i]
+		}
+	}
+	return ""
+}
+
+// parseHint parses the single hint at the start of tokens,
+// returning it and the tokens after it.
+func parseHint(tokens []string) (hint, []string, error) {
+	var h hint
+	cipherLetter, err := hintLetter(tokens[0])
+	if err != nil {
+		return h, nil, err
+	}
+	not, n := hintOperator(tokens[1:])
+	if n == 0 {
+		return h, nil, fmt.Errorf("%q should be followed by =, != or equals", tokens[0])
+	}
+	h.not = not
+	rest := tokens[1+n:]
+	if len(rest) == 0 {
+		return h, nil, fmt.Errorf("half a hint, no clear text letter after %s", strings.Join(tokens, " "))
+	}
+	clearLetter, err := hintLetter(rest[0])
+	if err != nil {
+		return h, nil, err
+	}
+	rest = rest[1:]
+	if len(rest) > 0 && !isHintSeparator(rest[0]) {
+		return h, nil, fmt.Errorf("%q after hint %c %s %c", rest[0], cipherLetter, hintOp(h.not), clearLetter)
+	}
+	h.cipherLetter, h.clearLetter = cipherLetter, clearLetter
+	return h, rest, nil
+}
+
+// hintTokens splits a hint line into lower case words, operators and
+// separators, so "X!=e, y=g." becomes "x", "≠", "e", ",", "y", "=", "g".
+func hintTokens(line string) []string {
+	line = strings.ToLower(line)
+	line = strings.TrimRight(strings.TrimSpace(line), ".")
+	line = strings.ReplaceAll(line, "!=", " ≠ ")
+	var spaced strings.Builder
+	for _, r := range line {
+		switch r {
+		case '=', '≠', ',', ';':
+			spaced.WriteString(" " + string(r) + " ")
+		default:
+			spaced.WriteRune(r)
+		}
+	}
+	return strings.Fields(spaced.String())
+}
+
+// hintLetter is the letter of a hint token, which has to be a single letter.
+func hintLetter(token string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(token)
+	if size != len(token) || !unicode.IsLetter(r) {
+		return 0, fmt.Errorf("%q isn't a single letter", token)
+	}
+	return foldCase(r), nil
+}
+
+func hintOp(not bool) string {
+	if not {
+		return "!="
+	}
+	return "="
+}
+
+// AddHint adds hint cipher letter = clear text letter to the puzzle.
+// Upper case letters become lower case. It's an error if the puzzle
+// doesn't have cipherLetter, or the hint contradicts one the puzzle
+// already has: cipherLetter with a different clear text letter, another
+// cipher letter with the same clear text letter, or a "!=" hint.
+func (p *Puzzle) AddHint(cipherLetter, clearLetter rune) error {
+	cipherLetter, clearLetter = foldCase(cipherLetter), foldCase(clearLetter)
+	if err := p.checkHint(cipherLetter, clearLetter); err != nil {
+		return err
+	}
+	if prev, ok := p.Hints[cipherLetter]; ok && prev != clearLetter {
+		return fmt.Errorf("hint %c = %c contradicts hint %c = %c", cipherLetter, clearLetter, cipherLetter, prev)
+	}
+	for c, l := range p.Hints {
+		if l == clearLetter && c != cipherLetter {
+			return fmt.Errorf("hint %c = %c contradicts hint %c = %c", cipherLetter, clearLetter, c, l)
+		}
+	}
+	if containsRune(p.NotHints[cipherLetter], clearLetter) {
+		return fmt.Errorf("hint %c = %c contradicts hint %c != %c", cipherLetter, clearLetter, cipherLetter, clearLetter)
+	}
+	if p.Hints == nil {
+		p.Hints = make(map[rune]rune)
+	}
+	p.Hints[cipherLetter] = clearLetter
+	return nil
+}
+
+// AddNotHint adds hint cipher letter != clear text letter to the puzzle,
+// which rules out clearLetter as the solution of cipherLetter. Like
+// AddHint, the puzzle has to have cipherLetter, and the hint can't
+// contradict a hint cipherLetter = clearLetter.
+func (p *Puzzle) AddNotHint(cipherLetter, clearLetter rune) error {
+	cipherLetter, clearLetter = foldCase(cipherLetter), foldCase(clearLetter)
+	if err := p.checkHint(cipherLetter, clearLetter); err != nil {
+		return err
+	}
+	if p.Hints[cipherLetter] == clearLetter {
+		return fmt.Errorf("hint %c != %c contradicts hint %c = %c", cipherLetter, clearLetter, cipherLetter, clearLetter)
+	}
+	if containsRune(p.NotHints[cipherLetter], clearLetter) {
+		return nil
+	}
+	if p.NotHints == nil {
+		p.NotHints = make(map[rune][]rune)
+	}
+	p.NotHints[cipherLetter] = append(p.NotHints[cipherLetter], clearLetter)
+	return nil
+}
+
+// checkSelfHints rejects a hint that a cipher letter is itself, "q=q",
+// when letters can't encode themselves. Parsing can't know, since
+// whether they can is up to the solve's options.
+func (p *Puzzle) checkSelfHints(ab *Alphabet, encodeSelf bool) error {
+	if encodeSelf {
+		return nil
+	}
+	for cipherLetter, clearLetter := range p.Hints {
+		if ab.NormalizeLetter(clearLetter) == cipherLetter {
+			return fmt.Errorf("hint %c = %c, but letters can't encode themselves", cipherLetter, clearLetter)
+		}
+	}
+	return nil
+}
+
+// checkHint makes sure both letters of a hint are letters, and that
+// the puzzle has the cipher letter.
+func (p *Puzzle) checkHint(cipherLetter, clearLetter rune) error {
+	if !unicode.IsLetter(cipherLetter) {
+		return fmt.Errorf("hint cipher letter %q isn't a letter", cipherLetter)
+	}
+	if !unicode.IsLetter(clearLetter) {
+		return fmt.Errorf("hint clear text letter %q isn't a letter", clearLetter)
+	}
+	if !containsRune(p.CipherLetters, cipherLetter) {
+		return fmt.Errorf("hint cipher letter %c isn't in the puzzle", cipherLetter)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package qp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsHintLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"x=g", true},
+		{"X = G.", true},
+		{"x != g", true},
+		{"x ≠ g", true},
+		{"If X equals G", true},
+		{"y does not equal q", true},
+		{"x doesn't equal t", true},
+		{"xyz abc, wxyv!", false},
+		{"don't qxf't", false},
+		{"x=", true},
+		{"QXF EQUALS ABC", false},
+		{"Q EQUALS", false},
+		{"Y EQUAL Q", false},
+		{"ZY DOES NOT EQUAL QXF", false},
+	}
+	for _, tt := range tests {
+		if got := isHintLine(tt.line); got != tt.want {
+			t.Errorf("isHintLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseHints(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []hint
+		wantErr bool
+	}{
+		{line: "x=g", want: []hint{{'x', 'g', false}}},
+		{line: "A=b, C=d", want: []hint{{'a', 'b', false}, {'c', 'd', false}}},
+		{line: "If X equals G.", want: []hint{{'x', 'g', false}}},
+		{line: "X!=e and Y does not equal q", want: []hint{{'x', 'e', true}, {'y', 'q', true}}},
+		{line: "x doesn't equal t; y ≠ u", want: []hint{{'x', 't', true}, {'y', 'u', true}}},
+		{line: "x=g, and if y=h", want: []hint{{'x', 'g', false}, {'y', 'h', false}}},
+		{line: "x=g=h", wantErr: true},
+		{line: "=q", wantErr: true},
+		{line: "x=", wantErr: true},
+		{line: "xy=g", wantErr: true},
+		{line: "x=g y=h", wantErr: true},
+		{line: "x equal g", wantErr: true},
+		{line: "x=t and", wantErr: true},
+		{line: "x=t,", wantErr: true},
+		{line: "and", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHints(tt.line)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseHints(%q) = %v, want an error", tt.line, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseHints(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseHints(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestCheckSelfHints(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("qxf\nq=q\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := puzzle.checkSelfHints(nil, false); err == nil {
+		t.Error("hint q=q without encodeSelf: no error")
+	}
+	if err := puzzle.checkSelfHints(nil, true); err != nil {
+		t.Errorf("hint q=q with encodeSelf: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package qp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxHybridCombinations limits how many combinations of candidate clear
+// text letters chooseByFitness will score.
+const maxHybridCombinations = 1 << 16
+
+// chooseByFitness finishes off a puzzle the cycles couldn't, by trying
+// every combination of the unsolved cipher letters' remaining candidate
+// clear text letters from the last cycle, and marking as solved the
+// combination whose clear text has the best n-gram score. It returns
+// the cipher letters it chose clear text letters for.
+func chooseByFitness(solved *Solved, model *NgramModel, puzzlewords [][]byte, possibleLetters map[rune]LetterSet, encodeSelf bool) []rune {
+	w := solved.out()
+
+	var letters []rune
+	candidates := make(map[rune][]rune)
+	combinations := 1
+	for _, cipherLetter := range solved.CipherLetters {
+		if _, ok := solved.SolvedLetters[cipherLetter]; ok {
+			continue
+		}
+		clearLetters := possibleLetters[cipherLetter] &^ solved.ClearLetters &^ solved.Excluded[cipherLetter]
+		if !encodeSelf {
+			clearLetters = solved.Alphabet.Remove(clearLetters, cipherLetter)
+		}
+		if clearLetters == 0 {
+			continue
+		}
+		candidates[cipherLetter] = solved.Alphabet.Runes(clearLetters)
+		letters = append(letters, cipherLetter)
+		combinations *= len(candidates[cipherLetter])
+		if combinations > maxHybridCombinations {
+			fmt.Fprintf(w, "more than %d combinations of candidate letters, not choosing by n-gram fitness\n", maxHybridCombinations)
+			return nil
+		}
+	}
+	if len(letters) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "---start choosing %d letters by %d-gram fitness, %d combinations---\n\n", len(letters), model.N, combinations)
+
+	key := make(map[rune]rune)
+	for cipherLetter, clearLetter := range solved.SolvedLetters {
+		key[cipherLetter] = clearLetter
+	}
+	var used LetterSet
+	var best map[rune]rune
+	bestScore := 0.0
+	tried := 0
+
+	// choose tries every candidate for letters[n], then recurses to
+	// letters[n+1], scoring when all the letters have a candidate.
+	var choose func(n int)
+	choose = func(n int) {
+		if n == len(letters) {
+			tried++
+			score := wordsFitness(model, puzzlewords, key)
+			if best == nil || score > bestScore {
+				best = make(map[rune]rune)
+				for _, cipherLetter := range letters {
+					best[cipherLetter] = key[cipherLetter]
+				}
+				bestScore = score
+			}
+			return
+		}
+		cipherLetter := letters[n]
+		for _, clearLetter := range candidates[cipherLetter] {
+			if solved.Alphabet.Has(used, clearLetter) {
+				continue
+			}
+			used = solved.Alphabet.Add(used, clearLetter)
+			key[cipherLetter] = clearLetter
+			choose(n + 1)
+			delete(key, cipherLetter)
+			used = solved.Alphabet.Remove(used, clearLetter)
+		}
+	}
+	choose(0)
+
+	if best == nil {
+		fmt.Fprintf(w, "no combination of candidate letters fits\n")
+		return nil
+	}
+	fmt.Fprintf(w, "scored %d combinations, best %d-gram score %.2f\n", tried, model.N, bestScore)
+	for _, cipherLetter := range letters {
+		fmt.Fprintf(w, "cipher letter %c chosen, not forced, as %c\n", cipherLetter, best[cipherLetter])
+		solved.mark(cipherLetter, best[cipherLetter], Reason{Kind: FitnessReason, Count: tried, N: model.N})
+	}
+	return letters
+}
+
+// wordsFitness deciphers words with key, and sums the n-gram scores
+// of the runs of deciphered letters.
+func wordsFitness(model *NgramModel, words [][]byte, key map[rune]rune) float64 {
+	score := 0.0
+	for _, word := range words {
+		clear := clearWord(word, key)
+		for _, run := range strings.Split(clear, "?") {
+			score += model.Score(run)
+		}
+	}
+	return score
+}
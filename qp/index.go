@@ -0,0 +1,356 @@
+package qp
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	indexMagic   = "cryptoquip shape index\n"
+	indexVersion = 5
+
+	// IndexSuffix gets appended to a dictionary file's name to name
+	// its shape-index file, see LoadDictionary.
+	IndexSuffix = ".shapeidx"
+)
+
+// IndexHeader starts a shape-index file. A shape-index file is a
+// dictionary file already read, shaped and sorted, see CompileIndex.
+//
+// After the magic string, a shape-index file is all unsigned varints,
+// strings as a length then bytes: the header's version, source hash,
+// source name, word count, whether there are counts, and alphabet name,
+// letters and folding, then the number of shapes, then for each
+// shape, the shape, its number of words, each word, each word's count
+// if the dictionary has counts, and for each position of the shape,
+// the LetterSet of the words' letters at that position.
+type IndexHeader struct {
+	Version    int
+	Source     string            // dictionary file name
+	SourceHash [sha256.Size]byte // SHA-256 of the dictionary file's contents
+	Words      int               // number of dictionary words
+	HasCounts  bool              // whether the dictionary has word counts
+	Alphabet   string            // name of the dictionary's alphabet
+	Letters    string            // letters of the alphabet, in order
+	Fold       bool              // whether the alphabet folds accents
+}
+
+// CompileIndex reads dictionary file dictName of alphabet ab, and
+// writes what it found, with the per-position letters of each shape,
+// to shape-index file indexName, where ReadIndex can read it back faster.
+func CompileIndex(dictName, indexName string, ab *Alphabet) (*IndexHeader, error) {
+	hash, err := fileHash(dictName)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ReadDictionary(dictName, ab)
+	if err != nil {
+		return nil, err
+	}
+	header := &IndexHeader{
+		Version:    indexVersion,
+		Source:     dictName,
+		SourceHash: hash,
+		HasCounts:  len(d.Counts) > 0,
+		Alphabet:   d.Alphabet.Name,
+		Letters:    string(d.Alphabet.Letters()),
+		Fold:       d.Alphabet.Folds(),
+	}
+	for _, words := range d.Shapes {
+		header.Words += len(words)
+	}
+
+	fout, err := os.Create(indexName)
+	if err != nil {
+		return nil, err
+	}
+	iw := &indexWriter{w: bufio.NewWriter(fout)}
+	iw.w.WriteString(indexMagic)
+	iw.header(header)
+
+	// sorted shapes make the same dictionary compile to the same file
+	var shapes []string
+	for shape := range d.Shapes {
+		shapes = append(shapes, shape)
+	}
+	sort.Strings(shapes)
+	letters := d.RunesDict()
+
+	iw.uvarint(len(shapes))
+	for _, shape := range shapes {
+		words := d.Shapes[shape]
+		iw.str(shape)
+		iw.uvarint(len(words))
+		for _, word := range words {
+			iw.str(word)
+		}
+		if header.HasCounts {
+			for _, word := range words {
+				iw.uvarint(d.Counts[word])
+			}
+		}
+		for _, set := range letters[shape].Runes {
+			iw.uvarint(int(set))
+		}
+	}
+
+	if err := iw.w.Flush(); err != nil {
+		fout.Close()
+		return nil, fmt.Errorf("writing shape index %s: %w", indexName, err)
+	}
+	return header, fout.Close()
+}
+
+// ReadIndexHeader reads just the header of shape-index file indexName.
+func ReadIndexHeader(indexName string) (*IndexHeader, error) {
+	fin, err := os.Open(indexName)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+	// The header is small, but the source file name could be long
+	buf := make([]byte, len(indexMagic)+4096)
+	n, err := io.ReadFull(fin, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("reading shape index %s: %w", indexName, err)
+	}
+	ir := &indexReader{buf: buf[:n]}
+	return ir.header(indexName)
+}
+
+// ReadIndex reads shape-index file indexName, as written by CompileIndex.
+// It doesn't check whether the index is up to date with its dictionary.
+func ReadIndex(indexName string) (*Dictionary, error) {
+	buf, err := os.ReadFile(indexName)
+	if err != nil {
+		return nil, err
+	}
+	ir := &indexReader{buf: buf}
+	header, err := ir.header(indexName)
+	if err != nil {
+		return nil, err
+	}
+	ab, err := NewAlphabet(header.Alphabet, header.Letters, header.Fold)
+	if err != nil {
+		return nil, fmt.Errorf("reading shape index %s: %w", indexName, err)
+	}
+
+	d := &Dictionary{
+		Shapes:       make(map[string][]string),
+		Counts:       make(map[string]int),
+		IndexFile:    indexName,
+		Alphabet:     ab,
+		indexLetters: make(map[string][]byte),
+	}
+	// one string for the whole file, so each word is just a slice of it
+	text := string(buf)
+	substr := func(b []byte) string {
+		// b ends where what's left to read starts
+		start := len(buf) - len(ir.buf) - len(b)
+		return text[start : start+len(b)]
+	}
+
+	shapeCount := ir.uvarint()
+	for i := 0; i < shapeCount && ir.err == nil; i++ {
+		shape := substr(ir.bytes())
+		wordCount := ir.uvarint()
+		if wordCount > len(ir.buf) {
+			// each word takes at least a byte
+			ir.err = errIndexTruncated
+			break
+		}
+		words := make([]string, wordCount)
+		for n := range words {
+			words[n] = substr(ir.bytes())
+		}
+		if header.HasCounts {
+			for _, word := range words {
+				d.Counts[word] = ir.uvarint()
+			}
+		}
+		// Only some uses of a Dictionary need the letters, so
+		// RunesDict decodes them when asked.
+		start := ir.buf
+		for idx := shapeLength(shape); idx > 0; idx-- {
+			ir.uvarint()
+		}
+		d.Shapes[shape] = words
+		d.indexLetters[shape] = start[:len(start)-len(ir.buf)]
+	}
+	if ir.err != nil {
+		return nil, fmt.Errorf("reading shape index %s: %w", indexName, ir.err)
+	}
+	return d, nil
+}
+
+// indexEntry decodes the per-position letters of a shape
+// from a shape-index file.
+func indexEntry(shape string, letters []byte) *Entry {
+	ir := &indexReader{buf: letters}
+	length := shapeLength(shape)
+	entry := &Entry{Length: length, Runes: make([]LetterSet, length)}
+	for idx := range entry.Runes {
+		entry.Runes[idx] = LetterSet(ir.uvarint())
+	}
+	return entry
+}
+
+// IndexUpToDate reports whether shape-index file indexName got compiled
+// from the current contents of dictionary file dictName.
+func IndexUpToDate(dictName, indexName string) (bool, error) {
+	header, err := ReadIndexHeader(indexName)
+	if err != nil {
+		return false, err
+	}
+	hash, err := fileHash(dictName)
+	if err != nil {
+		return false, err
+	}
+	return header.SourceHash == hash, nil
+}
+
+// LoadDictionary reads dictionary file dictName's shape-index file,
+// dictName plus IndexSuffix, if it's up to date with dictName, and
+// compiled for alphabet ab, English if ab is nil. Otherwise it reads
+// dictName itself, with ReadDictionary. A shape-index file it couldn't
+// use doesn't make an error, since dictName is still good, but it ends
+// up in the Dictionary's IndexError.
+func LoadDictionary(dictName string, ab *Alphabet) (*Dictionary, error) {
+	indexName := dictName + IndexSuffix
+	upToDate, indexErr := IndexUpToDate(dictName, indexName)
+	if errors.Is(indexErr, os.ErrNotExist) {
+		indexErr = nil
+	}
+	if upToDate {
+		d, err := ReadIndex(indexName)
+		switch {
+		case err != nil:
+			indexErr = err
+		case !d.Alphabet.same(ab):
+			indexErr = fmt.Errorf("%s has alphabet %s, not %s", indexName, d.Alphabet, ab)
+		default:
+			return d, nil
+		}
+	}
+	d, err := ReadDictionary(dictName, ab)
+	if err != nil {
+		return nil, err
+	}
+	d.IndexError = indexErr
+	return d, nil
+}
+
+func fileHash(fileName string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+	fin, err := os.Open(fileName)
+	if err != nil {
+		return hash, err
+	}
+	defer fin.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, fin); err != nil {
+		return hash, err
+	}
+	copy(hash[:], h.Sum(nil))
+	return hash, nil
+}
+
+// indexWriter writes the parts of a shape-index file. Errors stick
+// in the bufio.Writer until Flush.
+type indexWriter struct {
+	w   *bufio.Writer
+	tmp [binary.MaxVarintLen64]byte
+}
+
+func (iw *indexWriter) uvarint(n int) {
+	iw.w.Write(iw.tmp[:binary.PutUvarint(iw.tmp[:], uint64(n))])
+}
+
+func (iw *indexWriter) str(s string) {
+	iw.uvarint(len(s))
+	iw.w.WriteString(s)
+}
+
+func (iw *indexWriter) header(header *IndexHeader) {
+	iw.uvarint(header.Version)
+	iw.str(string(header.SourceHash[:]))
+	iw.str(header.Source)
+	iw.uvarint(header.Words)
+	hasCounts := 0
+	if header.HasCounts {
+		hasCounts = 1
+	}
+	iw.uvarint(hasCounts)
+	iw.str(header.Alphabet)
+	iw.str(header.Letters)
+	fold := 0
+	if header.Fold {
+		fold = 1
+	}
+	iw.uvarint(fold)
+}
+
+// indexReader picks apart the contents of a shape-index file. After
+// the first problem, it only returns zero values, and err says why.
+type indexReader struct {
+	buf []byte // what's left to read
+	err error
+}
+
+var errIndexTruncated = errors.New("shape index truncated")
+
+func (ir *indexReader) uvarint() int {
+	if ir.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(ir.buf)
+	if size <= 0 {
+		ir.err = errIndexTruncated
+		return 0
+	}
+	ir.buf = ir.buf[size:]
+	return int(n)
+}
+
+func (ir *indexReader) bytes() []byte {
+	n := ir.uvarint()
+	if ir.err != nil {
+		return nil
+	}
+	if n > len(ir.buf) {
+		ir.err = errIndexTruncated
+		return nil
+	}
+	b := ir.buf[:n]
+	ir.buf = ir.buf[n:]
+	return b
+}
+
+func (ir *indexReader) header(indexName string) (*IndexHeader, error) {
+	if !bytes.HasPrefix(ir.buf, []byte(indexMagic)) {
+		return nil, fmt.Errorf("%s isn't a shape index", indexName)
+	}
+	ir.buf = ir.buf[len(indexMagic):]
+	header := &IndexHeader{Version: ir.uvarint()}
+	if ir.err == nil && header.Version != indexVersion {
+		return nil, fmt.Errorf("shape index %s version %d, should be %d", indexName, header.Version, indexVersion)
+	}
+	copy(header.SourceHash[:], ir.bytes())
+	header.Source = string(ir.bytes())
+	header.Words = ir.uvarint()
+	header.HasCounts = ir.uvarint() == 1
+	header.Alphabet = string(ir.bytes())
+	header.Letters = string(ir.bytes())
+	header.Fold = ir.uvarint() == 1
+	if ir.err != nil {
+		return nil, fmt.Errorf("reading shape index %s header: %w", indexName, ir.err)
+	}
+	return header, nil
+}
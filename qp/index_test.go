@@ -0,0 +1,142 @@
+package qp
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeDictionary writes a dictionary file in a temporary directory.
+func writeDictionary(t *testing.T, text string) string {
+	t.Helper()
+	dictName := filepath.Join(t.TempDir(), "words")
+	if err := os.WriteFile(dictName, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dictName
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	spanish, err := LanguageAlphabet("spanish", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		text string
+		ab   *Alphabet
+	}{
+		{"words", "the\nand\nthat\nsnakes\nhave\ndon't\ncafé\n", nil},
+		{"counts", "the\t100\nand\t80\nthey\t5\nthen\t7\nhave\t10\n", nil},
+		{"spanish", "año\namo\ncasa\nniño\npequeño\n", spanish},
+	}
+	for _, tt := range tests {
+		dictName := writeDictionary(t, tt.text)
+		want, err := ReadDictionary(dictName, tt.ab)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		indexName := dictName + IndexSuffix
+		header, err := CompileIndex(dictName, indexName, tt.ab)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := ReadIndex(indexName)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got.Shapes, want.Shapes) {
+			t.Errorf("%s: shapes %v, want %v", tt.name, got.Shapes, want.Shapes)
+		}
+		if !reflect.DeepEqual(got.Counts, want.Counts) {
+			t.Errorf("%s: counts %v, want %v", tt.name, got.Counts, want.Counts)
+		}
+		if !reflect.DeepEqual(got.RunesDict(), want.RunesDict()) {
+			t.Errorf("%s: per-position letters differ", tt.name)
+		}
+		if !got.Alphabet.same(want.Alphabet) {
+			t.Errorf("%s: alphabet %s, want %s", tt.name, got.Alphabet, want.Alphabet)
+		}
+
+		readHeader, err := ReadIndexHeader(indexName)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if *readHeader != *header {
+			t.Errorf("%s: header %+v, want %+v", tt.name, readHeader, header)
+		}
+	}
+}
+
+func TestLoadDictionary(t *testing.T) {
+	dictName := writeDictionary(t, "the\nand\nthat\nsnakes\n")
+	indexName := dictName + IndexSuffix
+
+	// no index
+	d, err := LoadDictionary(dictName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IndexFile != "" || d.IndexError != nil {
+		t.Errorf("without an index: index file %q, error %v", d.IndexFile, d.IndexError)
+	}
+
+	if _, err := CompileIndex(dictName, indexName, nil); err != nil {
+		t.Fatal(err)
+	}
+	d, err = LoadDictionary(dictName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IndexFile != indexName || d.IndexError != nil {
+		t.Errorf("with an index: index file %q, error %v", d.IndexFile, d.IndexError)
+	}
+
+	// an index of another alphabet doesn't get used
+	spanish, err := LanguageAlphabet("spanish", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = LoadDictionary(dictName, spanish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IndexFile != "" || d.IndexError == nil {
+		t.Errorf("index of another alphabet: index file %q, error %v", d.IndexFile, d.IndexError)
+	}
+
+	// an out of date index doesn't get used
+	if err := os.WriteFile(dictName, []byte("the\nand\nthat\nsnakes\nhave\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if upToDate, err := IndexUpToDate(dictName, indexName); err != nil || upToDate {
+		t.Errorf("changed dictionary: index up to date %v, error %v", upToDate, err)
+	}
+	d, err = LoadDictionary(dictName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IndexFile != "" || !reflect.DeepEqual(d.Shapes["0123"], []string{"have"}) {
+		t.Errorf("out of date index: index file %q, shape 0123 words %q", d.IndexFile, d.Shapes["0123"])
+	}
+
+	// a broken index doesn't get used, and doesn't stop solving
+	if _, err := CompileIndex(dictName, indexName, nil); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(indexName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(indexName, buf[:len(buf)-10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err = LoadDictionary(dictName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IndexFile != "" || d.IndexError == nil {
+		t.Errorf("broken index: index file %q, error %v", d.IndexFile, d.IndexError)
+	}
+}
@@ -0,0 +1,153 @@
+package qp
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// letterStrings turns runes into one-letter strings, since JSON has no
+// character type, and encoding/json would make runes into numbers.
+func letterStrings(letters []rune) []string {
+	strs := make([]string, 0, len(letters))
+	for _, l := range letters {
+		strs = append(strs, string(l))
+	}
+	return strs
+}
+
+// keyStrings turns a key of cipher letters to clear text letters
+// into a map of one-letter strings.
+func keyStrings(key map[rune]rune) map[string]string {
+	strs := make(map[string]string)
+	for cipherLetter, clearLetter := range key {
+		strs[string(cipherLetter)] = string(clearLetter)
+	}
+	return strs
+}
+
+// notHintStrings turns the clear text letters each cipher letter isn't
+// into one-letter strings, nil for none.
+func notHintStrings(notHints map[rune][]rune) map[string][]string {
+	if len(notHints) == 0 {
+		return nil
+	}
+	strs := make(map[string][]string)
+	for cipherLetter, clearLetters := range notHints {
+		strs[string(cipherLetter)] = letterStrings(clearLetters)
+	}
+	return strs
+}
+
+func bytesStrings(words [][]byte) []string {
+	strs := make([]string, 0, len(words))
+	for _, word := range words {
+		strs = append(strs, string(word))
+	}
+	return strs
+}
+
+// MarshalJSON represents a Puzzle with strings for cipher words
+// and one-letter strings for letters.
+func (p *Puzzle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Text          string              `json:"text"`
+		Words         []string            `json:"words"`
+		CipherLetters []string            `json:"cipher_letters"`
+		Hints         map[string]string   `json:"hints"`
+		NotHints      map[string][]string `json:"not_hints,omitempty"`
+		Known         map[string]string   `json:"known,omitempty"`
+		Line          int                 `json:"line,omitempty"`
+		Original      string              `json:"original,omitempty"`
+	}{
+		Text:          strings.Join(bytesStrings(p.Words), " "),
+		Words:         bytesStrings(p.Words),
+		CipherLetters: letterStrings(p.CipherLetters),
+		Hints:         keyStrings(p.Hints),
+		NotHints:      notHintStrings(p.NotHints),
+		Known:         keyStrings(p.Known),
+		Line:          p.Line,
+		Original:      p.Original,
+	})
+}
+
+// MarshalJSON represents a Solution with one-letter strings for letters.
+func (s *Solution) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Key   map[string]string `json:"key"`
+		Text  string            `json:"text"`
+		Score float64           `json:"score"`
+	}{
+		Key:   keyStrings(s.Key),
+		Text:  strings.Join(s.Words, " "),
+		Score: s.Score,
+	})
+}
+
+// MarshalJSON represents an Assignment with one-letter strings for
+// letters, and its Reason as the kind of deduction and an explanation.
+func (a Assignment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		CipherLetter string   `json:"cipher_letter"`
+		ClearLetter  string   `json:"clear_letter"`
+		Kind         string   `json:"kind"`
+		Reason       string   `json:"reason"`
+		Words        []string `json:"words,omitempty"`
+	}{
+		CipherLetter: string(a.CipherLetter),
+		ClearLetter:  string(a.ClearLetter),
+		Kind:         a.Reason.Kind.String(),
+		Reason:       a.Reason.String(),
+		Words:        a.Reason.Words,
+	})
+}
+
+// MarshalJSON represents a Result with one-letter strings for letters,
+// and adds the deciphered text and whether the solve is complete.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	candidates := make(map[string][]string)
+	for cipherLetter, clearLetters := range r.Candidates {
+		candidates[string(cipherLetter)] = letterStrings(clearLetters)
+	}
+	return json.Marshal(struct {
+		Complete       bool                `json:"complete"`
+		Key            map[string]string   `json:"key"`
+		Text           string              `json:"text"`
+		ClearText      string              `json:"clear_text"`
+		Words          []string            `json:"words"`
+		Solved         []string            `json:"solved"`
+		Guessed        []string            `json:"guessed"`
+		Unsolved       []string            `json:"unsolved"`
+		Candidates     map[string][]string `json:"candidates"`
+		WordCandidates map[string]int      `json:"word_candidates"`
+		Cycles         int                 `json:"cycles"`
+		Solutions      []*Solution         `json:"solutions,omitempty"`
+		Missing        []MissingWord       `json:"missing,omitempty"`
+		Deductions     []Assignment        `json:"deductions"`
+		Problems       []string            `json:"problems,omitempty"`
+	}{
+		Complete:       r.Complete(),
+		Key:            keyStrings(r.Key),
+		Text:           strings.Join(r.Words, " "),
+		ClearText:      r.ClearText,
+		Words:          r.Words,
+		Solved:         letterStrings(r.Solved),
+		Guessed:        letterStrings(r.Guessed),
+		Unsolved:       letterStrings(r.Unsolved),
+		Candidates:     candidates,
+		WordCandidates: r.WordCandidates,
+		Cycles:         r.Cycles,
+		Solutions:      r.Solutions,
+		Missing:        r.Missing,
+		Deductions:     r.Deductions,
+		Problems:       errorStrings(r.Problems),
+	})
+}
+
+// errorStrings is the messages of errs.
+func errorStrings(errs []error) []string {
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
@@ -0,0 +1,14 @@
+package qp
+
+import "math/bits"
+
+// LetterSet is a set of clear text letters of an Alphabet and the
+// apostrophe, a bit per letter. Intersecting two sets is a single AND,
+// rather than a map allocation. Which bit is which letter is up to the
+// Alphabet, see Alphabet.Add and Alphabet.Runes.
+type LetterSet uint64
+
+// Len is the number of letters in s.
+func (s LetterSet) Len() int {
+	return bits.OnesCount64(uint64(s))
+}
@@ -0,0 +1,122 @@
+package qp
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// NgramModel holds log probabilities of sequences of N clear text letters,
+// for scoring how much like the model's language some deciphered text looks.
+type NgramModel struct {
+	N        int
+	Alphabet *Alphabet
+	logProbs []float64 // indexed by letter numbers as base Alphabet.Len() digits
+	floor    float64   // log probability of n-grams never seen
+}
+
+// maxNgrams limits the size of an NgramModel's table of log probabilities.
+const maxNgrams = 1 << 24
+
+// NewNgramModel counts the n-grams of the letters of alphabet ab,
+// English if ab is nil, in a dictionary or corpus file. Letters of a
+// line run together, skipping spaces and punctuation, so running text
+// gives n-grams that cross word boundaries, but a dictionary only has
+// n-grams inside words. A line can have a count after a tab, like
+// ReadDictionary, which weights its n-grams.
+func NewNgramModel(fileName string, n int, ab *Alphabet) (*NgramModel, error) {
+	if n < 1 || n > 4 {
+		return nil, fmt.Errorf("n-gram length %d, should be 1 through 4", n)
+	}
+	ab = ab.orEnglish()
+
+	size := 1
+	for i := 0; i < n; i++ {
+		size *= ab.Len()
+	}
+	if size > maxNgrams {
+		return nil, fmt.Errorf("%d-grams of the %d letters of %s, too many", n, ab.Len(), ab)
+	}
+
+	fin, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+	counts := make([]float64, size)
+	total := 0.0
+
+	scanner := bufio.NewScanner(fin)
+	lineCounter := 0
+	for scanner.Scan() {
+		lineCounter++
+		line := ab.Normalize(scanner.Text())
+
+		weight := 1.0
+		if text, count, found := strings.Cut(line, "\t"); found {
+			c, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil {
+				return nil, &ErrDictionaryRead{FileName: fileName, Line: lineCounter, Err: err}
+			}
+			line = text
+			weight = float64(c)
+		}
+
+		letters := ab.letterNumbers(line)
+		for i := 0; i+n <= len(letters); i++ {
+			counts[ngramIndex(ab, letters[i:i+n])] += weight
+			total += weight
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ErrDictionaryRead{FileName: fileName, Line: lineCounter, Err: err}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no %d-letter sequences in %s", n, fileName)
+	}
+
+	m := &NgramModel{
+		N:        n,
+		Alphabet: ab,
+		logProbs: make([]float64, size),
+		floor:    math.Log10(0.01 / total),
+	}
+	for i, c := range counts {
+		if c == 0 {
+			m.logProbs[i] = m.floor
+			continue
+		}
+		m.logProbs[i] = math.Log10(c / total)
+	}
+	return m, nil
+}
+
+// ngramIndex turns a sequence of letter numbers, 0 for the first letter
+// of ab, 25 for 'z' in English, into an index into NgramModel.logProbs
+func ngramIndex(ab *Alphabet, letters []int) int {
+	idx := 0
+	size := ab.Len()
+	for _, l := range letters {
+		idx = idx*size + l
+	}
+	return idx
+}
+
+// score sums the log probabilities of all the n-grams in a sequence
+// of letter numbers. Higher scores look more like the model's text.
+func (m *NgramModel) score(letters []int) float64 {
+	score := 0.0
+	for i := 0; i+m.N <= len(letters); i++ {
+		score += m.logProbs[ngramIndex(m.Alphabet, letters[i:i+m.N])]
+	}
+	return score
+}
+
+// Score sums the log probabilities of all the n-grams in some clear
+// text, skipping anything that isn't a letter of the model's alphabet.
+func (m *NgramModel) Score(text string) float64 {
+	return m.score(m.Alphabet.letterNumbers(m.Alphabet.Normalize(text)))
+}
@@ -0,0 +1,317 @@
+package qp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wordPattern is what the clear text of a cipher word has to look like:
+// a set of allowed clear letters at each position, the same clear letter
+// wherever the cipher word has the same cipher letter, and different
+// clear letters for different cipher letters.
+type wordPattern struct {
+	ab      *Alphabet
+	letters []LetterSet // allowed clear letters at each position
+	first   []int       // first position of each position's cipher letter
+	clear   []rune      // scratch, the clear letters of a word being matched
+}
+
+// newWordPattern makes a wordPattern for cipherWord from the allowed
+// clear letters of each of its cipher letters.
+func newWordPattern(ab *Alphabet, cipherWord []rune, letters func(rune) LetterSet) *wordPattern {
+	p := &wordPattern{
+		ab:      ab,
+		letters: make([]LetterSet, len(cipherWord)),
+		first:   make([]int, len(cipherWord)),
+		clear:   make([]rune, len(cipherWord)),
+	}
+	for idx, c := range cipherWord {
+		p.letters[idx] = letters(c)
+		p.first[idx] = idx
+		for prev := 0; prev < idx; prev++ {
+			if cipherWord[prev] == c {
+				p.first[idx] = prev
+				break
+			}
+		}
+	}
+	return p
+}
+
+// match reports whether clear text word could be the cipher word
+// that p came from.
+func (p *wordPattern) match(word string) bool {
+	var used LetterSet
+	idx := 0
+	for _, r := range word {
+		if idx >= len(p.letters) || !p.ab.Has(p.letters[idx], r) {
+			return false
+		}
+		if first := p.first[idx]; first != idx {
+			// repeated cipher letter, repeated clear letter
+			if p.clear[first] != r {
+				return false
+			}
+		} else {
+			// new cipher letter, new clear letter
+			if p.ab.Has(used, r) {
+				return false
+			}
+			used = p.ab.Add(used, r)
+		}
+		p.clear[idx] = r
+		idx++
+	}
+	return idx == len(p.letters)
+}
+
+// String shows the allowed letters of each position, for verbose
+// output and Reason.Pattern. It looks like a regular expression: a set
+// of letters goes in brackets, a set of most of the alphabet shows the
+// letters it doesn't have, and "." is any letter.
+func (p *wordPattern) String() string {
+	alphabet := p.ab.All()
+	var sb strings.Builder
+	for _, m := range p.letters {
+		if l, ok := p.ab.Only(m); ok {
+			sb.WriteRune(l)
+			continue
+		}
+		switch missing := alphabet &^ m; {
+		case missing == 0:
+			sb.WriteByte('.')
+		case m.Len() > missing.Len():
+			sb.WriteString("[^" + p.ab.SetString(missing) + "]")
+		default:
+			sb.WriteString("[" + p.ab.SetString(m) + "]")
+		}
+	}
+	return sb.String()
+}
+
+// lettersForCipherLetter finds the clear letters that cipher letter
+// could match, from set m, which contains all of the letters that
+// the cipher letter represents. It returns an ErrNoCandidates if all
+// the letters in m are already solutions of other cipher letters.
+func lettersForCipherLetter(solved *Solved, cipherLetter rune, m LetterSet) (LetterSet, error) {
+	if sl, ok := solved.SolvedLetters[cipherLetter]; ok {
+		return solved.Alphabet.Set(sl), nil
+	}
+	if m.Len() < 2 {
+		// An empty m matches nothing, which should make the
+		// cipher word unmatched.
+		return m, nil
+	}
+	// Letters of m are potentially the solution for cipherLetter, unless
+	// already known a match for some other cipher letter.
+	letters := m &^ solved.ClearLetters
+	if letters == 0 {
+		return 0, newErrNoCandidates(solved.Alphabet, cipherLetter, m)
+	}
+	return letters, nil
+}
+
+type shapeMatch struct {
+	cipherWord    string
+	cipherRunes   []rune // cipherWord, a rune per letter
+	configuration string
+	pattern       *wordPattern
+}
+
+// cwMustMatch composes the patterns that cipherwords must match
+func cwMustMatch(solved *Solved, puzzlewords [][]byte, possibleLetters map[rune]LetterSet) ([]*shapeMatch, error) {
+
+	w := solved.out()
+	var smatches []*shapeMatch
+
+	cipherLetterSets := make(map[rune]LetterSet)
+	var err error
+	letters := func(c rune) LetterSet {
+		m, ok := cipherLetterSets[c]
+		if !ok && err == nil {
+			m, err = lettersForCipherLetter(solved, c, possibleLetters[c])
+			cipherLetterSets[c] = m
+		}
+		return m
+	}
+
+	for _, cipherword := range puzzlewords {
+		runes := []rune(string(cipherword))
+		pattern := newWordPattern(solved.Alphabet, runes, letters)
+		if err != nil {
+			return nil, err
+		}
+		if solved.Verbose {
+			fmt.Fprintf(w, "cipher word %q must match pattern %s\n", cipherword, pattern)
+		}
+		str := string(cipherword)
+		smatches = append(smatches,
+			&shapeMatch{
+				cipherWord:    str,
+				cipherRunes:   runes,
+				configuration: StringConfiguration(str),
+				pattern:       pattern,
+			},
+		)
+	}
+	return smatches, nil
+}
+
+// shapeDictFromPatterns makes a new "shape dictionary" from the previous
+// cycle's shape dictionary and the patterns composed from
+// the clear text letters from intersecting the previous cycle's
+// shape dictionary entries. It also returns the cipher words whose
+// patterns didn't match any dictionary words.
+func shapeDictFromPatterns(solved *Solved, shapeDict map[string][]string, shapeMatches []*shapeMatch) (map[string][]string, []string) {
+
+	w := solved.out()
+	newShapeDict := make(map[string][]string)
+	// cipher words of the same shape match some of the same words,
+	// which go in newShapeDict once
+	inNewShapeDict := make(map[string]map[string]bool)
+	var unmatched []string
+
+	// map keyed by cipher letter, values are sets of clear letters
+	// that match that cipher letter
+	lettersFromPatterns := make(map[rune]LetterSet)
+	// cipher words whose matches gave each cipher letter's clear letters
+	wordsFromPatterns := make(map[rune][]string)
+
+	if solved.Verbose {
+		fmt.Fprintf(w, "creating new shape dictionary with %d shape matchers\n", len(shapeMatches))
+	}
+
+	for _, sm := range shapeMatches {
+		if solved.Verbose {
+			fmt.Fprintf(w, "\trecreating shape dictionary for %s:%s - %s\n",
+				sm.cipherWord, sm.configuration, sm.pattern,
+			)
+		}
+		wordMatched := make(map[string]bool)
+		if solved.Verbose {
+			fmt.Fprintf(w, "\t%d shape matches for %s in current shape dictionary\n",
+				len(shapeDict[sm.configuration]),
+				sm.configuration,
+			)
+		}
+
+		patternMatches := 0
+
+		for _, shapeWord := range shapeDict[sm.configuration] {
+			if !sm.pattern.match(shapeWord) {
+				continue
+			}
+			if wordMatched[shapeWord] {
+				continue
+			}
+			patternMatches++
+			if inNewShapeDict[sm.configuration] == nil {
+				inNewShapeDict[sm.configuration] = make(map[string]bool)
+			}
+			if !inNewShapeDict[sm.configuration][shapeWord] {
+				newShapeDict[sm.configuration] = append(
+					newShapeDict[sm.configuration],
+					shapeWord,
+				)
+				inNewShapeDict[sm.configuration][shapeWord] = true
+			}
+			wordMatched[shapeWord] = true
+
+			idx := 0
+			for _, sl := range shapeWord {
+				// sl cleartext letter could solve sm.cipherRunes[idx]
+				cl := sm.cipherRunes[idx]
+				addWord(wordsFromPatterns, cl, sm.cipherWord)
+				lettersFromPatterns[cl] = solved.Alphabet.Add(lettersFromPatterns[cl], sl)
+				idx++
+			}
+		}
+		if solved.Verbose {
+			fmt.Fprintf(w, "\tpattern %s matched %d dictionary words\n", sm.pattern, patternMatches)
+			fmt.Fprintf(w, "\tcipherword %q could be %d dictionary words\n", sm.cipherWord, len(wordMatched))
+			if len(wordMatched) < 11 {
+				for word := range wordMatched {
+					fmt.Fprintf(w, "\t\t%s\n", word)
+				}
+			}
+
+		}
+		if len(wordMatched) == 0 {
+			unmatched = append(unmatched, sm.cipherWord)
+		} else if len(wordMatched) == 1 {
+			// we can match all the letters in sm.cipherWord
+			// to the clear text letters in newShapeDict[sm.configuration],
+			// setting a key/value in the map solvedLetters.
+			// Unless there's already a value in solvedLetters for the cipher letter,
+			// and it's not the letter in sm.cipherWord[i], in which case
+			// retract all of this word's letters.
+			var soleMatch string
+			for soleMatch = range wordMatched {
+			}
+			if solved.Verbose {
+				fmt.Fprintf(w, "single match of %q in word shapes dictionary %q\n",
+					sm.cipherWord,
+					soleMatch,
+				)
+			}
+			reason := Reason{
+				Kind:    SoleMatchReason,
+				Words:   []string{sm.cipherWord},
+				Match:   soleMatch,
+				Pattern: sm.pattern.String(),
+			}
+			snapshot := solved.Snapshot()
+			soleMatchRunes := []rune(soleMatch)
+			for idx, cl := range sm.cipherRunes {
+				if err := solved.SetSolved(cl, soleMatchRunes[idx], reason); err != nil {
+					solved.problem(err)
+					fmt.Fprintf(w, "retracting %q as %q\n", sm.cipherWord, soleMatch)
+					solved.Rollback(snapshot)
+					break
+				}
+			}
+		} else if len(wordMatched) > 1 {
+			// See if some letter(s) are the same in the same position of all words
+			letters := make([]LetterSet, len(sm.cipherRunes))
+			for word := range wordMatched {
+				idx := 0
+				for _, r := range word {
+					letters[idx] = solved.Alphabet.Add(letters[idx], r)
+					idx++
+				}
+			}
+			for idx, m := range letters {
+				if c, ok := solved.Alphabet.Only(m); ok {
+					// There is only one cleartext letter at position idx
+					// in all of the matching-shape-words.
+					fmt.Fprintf(w, "At position %d in shape matches, cipher letter %c, only 1 clear letter: %c\n", idx, sm.cipherRunes[idx], c)
+					solved.mark(sm.cipherRunes[idx], c, Reason{
+						Kind:     UnanimousReason,
+						Words:    []string{sm.cipherWord},
+						Pattern:  sm.pattern.String(),
+						Position: idx,
+						Count:    len(wordMatched),
+					})
+				}
+			}
+		}
+	}
+
+	if solved.Verbose {
+		for r, ltrs := range lettersFromPatterns {
+			fmt.Fprintf(w, "cipher letter %c clear letters from patterns: ", r)
+			sortThenPrint(w, solved.Alphabet, ltrs)
+		}
+	}
+
+	for _, cipherLetter := range solved.CipherLetters {
+		if clearLetter, ok := solved.Alphabet.Only(lettersFromPatterns[cipherLetter]); ok {
+			solved.mark(cipherLetter, clearLetter, Reason{
+				Kind:  RegexpLettersReason,
+				Words: wordsFromPatterns[cipherLetter],
+			})
+		}
+	}
+
+	return newShapeDict, unmatched
+}
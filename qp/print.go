@@ -0,0 +1,120 @@
+package qp
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+)
+
+// shapeDictCharacterization prints out "size" of a shape dictionary,
+// a map[string][]string, where the map key is a word "shape" or "configuration",
+// and the key's associated value is a slice of string words that have that shape.
+func shapeDictCharacterization(w io.Writer, shapeDict map[string][]string, phrase string) {
+	wordCount := shapeDictWordCount(shapeDict)
+	fmt.Fprintf(w, "%s shape dictionary has %d shapes, %d words\n", phrase, len(shapeDict), wordCount)
+	if len(shapeDict) < 11 {
+		for shape, matches := range shapeDict {
+			fmt.Fprintf(w, "\tshape %s has %d matches\n", shape, len(matches))
+		}
+	}
+}
+
+// shapeDictWordCount counts all the words in a shape dictionary.
+func shapeDictWordCount(shapeDict map[string][]string) int {
+	wordCount := 0
+	for _, words := range shapeDict {
+		wordCount += len(words)
+	}
+	return wordCount
+}
+
+func printSolvedWords(w io.Writer, puzzlewords [][]byte, solved *Solved) {
+	lineLength := 0
+	cipherLine := ""
+	clearLine := ""
+	spacer := ""
+	for _, word := range puzzlewords {
+		cipherLine = fmt.Sprintf("%s%s%s", cipherLine, spacer, string(word))
+		clearLine = fmt.Sprintf("%s%s%s", clearLine, spacer, clearWord(word, solved.SolvedLetters))
+
+		spacer = " "
+		lineLength = utf8.RuneCountInString(cipherLine)
+		if lineLength > 72 {
+			fmt.Fprintln(w, cipherLine)
+			fmt.Fprintln(w, clearLine)
+			fmt.Fprintln(w)
+			cipherLine = ""
+			clearLine = ""
+			spacer = ""
+		}
+	}
+	lineLength = len(cipherLine)
+	if lineLength > 0 {
+		fmt.Fprintln(w, cipherLine)
+		fmt.Fprintln(w, clearLine)
+		fmt.Fprintln(w)
+	}
+}
+
+func printSortedPossible(w io.Writer, ab *Alphabet, cycle int, possibleLetters map[rune]LetterSet) {
+	var keys []rune
+	for cipherLetter := range possibleLetters {
+		keys = append(keys, cipherLetter)
+	}
+	sort.Sort(RuneSlice(keys))
+
+	fmt.Fprintf(w, "After cycle %d shape comparisons:\n", cycle)
+
+	for i := range keys {
+		printLetters(w, ab, keys[i], "", possibleLetters[keys[i]])
+	}
+}
+
+func printLetters(w io.Writer, ab *Alphabet, cipherLetter rune, format string, m LetterSet) {
+	ln := m.Len()
+	fmt.Fprintf(w, "cipher letter %c %s (%d):", cipherLetter, format, ln)
+	sortThenPrint(w, ab, m)
+}
+
+func sortThenPrint(w io.Writer, ab *Alphabet, m LetterSet) {
+	// Runes are already sorted
+	for _, l := range ab.Runes(m) {
+		fmt.Fprintf(w, " %c", l)
+	}
+	fmt.Fprintln(w)
+}
+
+// printSolvedLetters prints a human-comprehensible correspondence
+// of cipher- to solved-letters.
+func printSolvedLetters(solved *Solved) {
+	w := solved.out()
+	fmt.Fprintf(w, "\nSolved letters:\n")
+	for i := range solved.CipherLetters {
+		fmt.Fprintf(w, "%c ", solved.CipherLetters[i])
+	}
+	fmt.Fprintln(w)
+	for i := range solved.CipherLetters {
+		if clear, ok := solved.SolvedLetters[solved.CipherLetters[i]]; ok {
+			fmt.Fprintf(w, "%c ", clear)
+		} else {
+			fmt.Fprintf(w, "? ")
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// printSolutions prints enumerated solutions in rank order.
+func printSolutions(w io.Writer, solutions []*Solution) {
+	if len(solutions) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%d solutions:\n", len(solutions))
+	for i, solution := range solutions {
+		fmt.Fprintf(w, "%d. score %.2f\n", i+1, solution.Score)
+		for _, word := range solution.Words {
+			fmt.Fprintf(w, "%s ", word)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -2,51 +2,152 @@ package qp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
+// ReadPuzzle reads a puzzle file, returning the puzzle's cipher words,
+// its unique cipher words, its cipher letters and its hints. For a
+// multi-puzzle file, that's the first puzzle, see ParsePuzzles.
 func ReadPuzzle(fileName string, verbose bool) ([][]byte, [][]byte, []rune, map[rune]rune, error) {
-	buf, err := os.ReadFile(fileName)
+	puzzles, err := ReadPuzzles(fileName)
 	if err != nil {
 		if verbose {
 			fmt.Fprintf(os.Stderr, "reading file %s: %v\n", fileName, err)
 		}
 		return nil, nil, nil, nil, err
 	}
+	puzzle := &Puzzle{Hints: make(map[rune]rune)}
+	if len(puzzles) > 0 {
+		puzzle = puzzles[0]
+	}
+	return puzzle.Words, puzzle.UniqueWords, puzzle.CipherLetters, puzzle.Hints, nil
+}
+
+// ReadPuzzles reads all the puzzles of a multi-puzzle file,
+// see ParsePuzzles. A line that doesn't make sense gets an
+// *ErrPuzzleParse with the file's name.
+func ReadPuzzles(fileName string) ([]*Puzzle, error) {
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	puzzles, err := ParsePuzzles(buf)
+	var pe *ErrPuzzleParse
+	if errors.As(err, &pe) {
+		pe.FileName = fileName
+	}
+	return puzzles, err
+}
 
+// ParsePuzzles splits the text of a multi-puzzle file into puzzles at
+// separator lines, lines of 3 or more hyphens and nothing else, and
+// parses each puzzle with ParsePuzzle. A piece without cipher words,
+// a comment at the top of the file say, isn't a puzzle. Each Puzzle's
+// Line, and the Line of an *ErrPuzzleParse, is a line number in buf.
+func ParsePuzzles(buf []byte) ([]*Puzzle, error) {
+	var puzzles []*Puzzle
+	lines := bytes.Split(buf, []byte{'\n'})
+	start := 0
+	for n := 0; n <= len(lines); n++ {
+		if n < len(lines) && !isSeparator(lines[n]) {
+			continue
+		}
+		puzzle, err := ParsePuzzle(bytes.Join(lines[start:n], []byte{'\n'}))
+		if err != nil {
+			var pe *ErrPuzzleParse
+			if errors.As(err, &pe) {
+				pe.Line += start
+			}
+			return nil, err
+		}
+		if len(puzzle.Words) > 0 {
+			puzzle.Line += start
+			puzzles = append(puzzles, puzzle)
+		}
+		start = n + 1
+	}
+	return puzzles, nil
+}
+
+func isSeparator(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	return len(line) >= 3 && len(bytes.Trim(line, "-")) == 0
+}
+
+// ParsePuzzle picks apart the text of a puzzle file, hint lines, see
+// parseHints, and lines of cipher words, ignoring '#' comment lines.
+// A malformed hint, a hint that contradicts another one, or a hint
+// whose cipher letter isn't in the puzzle gets an *ErrPuzzleParse with
+// the hint's line number. Cipher words
+// are the runs of letters and inner apostrophes of a line, lower case,
+// see cipherWords. The lines as they are, punctuation, case and all, go
+// in the Puzzle's Original.
+//
+// A puzzle can have a known solution, either "# clear" and "# cipher"
+// comment lines of letters in the same order, the way the encoder writes
+// them, or the clear text, after a "# Solution" comment line, with or
+// without '#' at the start of each line. The known solution doesn't
+// count as cipher words.
+func ParsePuzzle(buf []byte) (*Puzzle, error) {
 	uniquePuzzleWords := make(map[string]bool)
 	var words [][]byte
-	var enciphered, clear rune
 	letters := make(map[rune]bool)
-	hints := make(map[rune]rune)
+	firstLine := 0
 
-	for _, line := range bytes.Split(buf, []byte{'\n'}) {
+	// hints wait until all the cipher letters are in
+	type hintLine struct {
+		n     int
+		text  string
+		hints []hint
+	}
+	var hintLines []hintLine
+
+	inSolution := false
+	var solution, clearLetters, cipherLetters [][]byte
+	var original []string
+
+	for n, line := range bytes.Split(buf, []byte{'\n'}) {
 		line = bytes.TrimSpace(line)
+		if inSolution {
+			solution = append(solution, cipherWords(bytes.TrimLeft(line, "#"))...)
+			continue
+		}
 		if len(line) == 0 || line[0] == '#' {
-			if bytes.Contains(line, []byte("Solution")) {
-				break
+			fields := bytes.Fields(line)
+			switch {
+			case bytes.Contains(line, []byte("Solution")):
+				inSolution = true
+			case len(fields) > 1 && string(fields[1]) == "clear":
+				clearLetters = fields[2:]
+			case len(fields) > 1 && string(fields[1]) == "cipher":
+				cipherLetters = fields[2:]
 			}
 			continue
 		}
-		if bytes.ContainsRune(line, '=') {
-			fields := bytes.Split(line, []byte{'='})
-			enciphered = rune(fields[0][0])
-			clear = rune(fields[len(fields)-1][0])
-			hints[enciphered] = clear
+		if isHintLine(string(line)) {
+			hints, err := parseHints(string(line))
+			if err != nil {
+				return nil, &ErrPuzzleParse{Line: n + 1, Text: string(line), Err: err}
+			}
+			hintLines = append(hintLines, hintLine{n: n + 1, text: string(line), hints: hints})
 			continue
 		}
-		for _, word := range bytes.Fields(line) {
-			var wo []byte
-			// Weed out some punctuation: .:,
-			for i := range word {
-				switch word[i] {
-				case ':', '.', ',', '"':
-				default:
-					wo = append(wo, word[i])
-					letters[rune(word[i])] = true
-				}
+		if firstLine == 0 {
+			firstLine = n + 1
+		}
+		line = norm.NFC.Bytes(line)
+		original = append(original, string(line))
+		for _, wo := range cipherWords(line) {
+			for _, r := range bytes.Runes(wo) {
+				letters[r] = true
 			}
 			uniquePuzzleWords[string(wo)] = true
 			words = append(words, wo)
@@ -64,6 +165,110 @@ func ReadPuzzle(fileName string, verbose bool) ([][]byte, [][]byte, []rune, map[
 	for pw := range uniquePuzzleWords {
 		upw = append(upw, []byte(pw))
 	}
+	// the same order every time, for the same results every time
+	sort.Slice(upw, func(i, j int) bool { return bytes.Compare(upw[i], upw[j]) < 0 })
 
-	return words, upw, uniqueLetters, hints, nil
+	known := letterKey(cipherLetters, clearLetters)
+	if solution != nil {
+		known = wordKey(words, solution)
+	}
+
+	puzzle := &Puzzle{
+		Words:         words,
+		UniqueWords:   upw,
+		CipherLetters: uniqueLetters,
+		Hints:         make(map[rune]rune),
+		Known:         known,
+		Line:          firstLine,
+		Original:      strings.Join(original, "\n"),
+	}
+	for _, hl := range hintLines {
+		for _, h := range hl.hints {
+			var err error
+			if h.not {
+				err = puzzle.AddNotHint(h.cipherLetter, h.clearLetter)
+			} else {
+				err = puzzle.AddHint(h.cipherLetter, h.clearLetter)
+			}
+			if err != nil {
+				return nil, &ErrPuzzleParse{Line: hl.n, Text: hl.text, Err: err}
+			}
+		}
+	}
+	return puzzle, nil
+}
+
+// cipherWords splits a line of cipher text into words, the runs of
+// letters and apostrophes, so that punctuation, '?' and '(' as much as
+// ',' and '.', doesn't end up in a word's shape. Dashes and anything
+// else between letters split words too, so a hyphenated word's parts
+// get shaped separately. Letters become lower case, see foldCase, and
+// typeset apostrophes become plain ones. Only apostrophes between
+// letters stay: at the start or end of a word they're single quote
+// marks, punctuation, so 'qxf' is qxf.
+func cipherWords(line []byte) [][]byte {
+	var words [][]byte
+	var word []byte
+	end := func() {
+		word = bytes.Trim(word, "'")
+		if len(word) > 0 {
+			words = append(words, word)
+		}
+		word = nil
+	}
+	for _, r := range string(line) {
+		switch {
+		case unicode.IsLetter(r):
+			word = utf8.AppendRune(word, foldCase(r))
+		case r == '\'' || r == '’':
+			word = append(word, '\'')
+		default:
+			end()
+		}
+	}
+	end()
+	return words
+}
+
+// letterKey pairs up single cipher and clear text letters, the fields
+// of "# cipher" and "# clear" lines. It returns nil if they don't.
+func letterKey(cipherLetters, clearLetters [][]byte) map[rune]rune {
+	if len(cipherLetters) == 0 || len(cipherLetters) != len(clearLetters) {
+		return nil
+	}
+	key := make(map[rune]rune)
+	for i := range cipherLetters {
+		c := []rune(string(cipherLetters[i]))
+		l := []rune(string(clearLetters[i]))
+		if len(c) != 1 || len(l) != 1 {
+			return nil
+		}
+		key[foldCase(c[0])] = foldCase(l[0])
+	}
+	return key
+}
+
+// wordKey pairs up the letters of cipher words and their clear text.
+// It returns nil if the words don't line up, or the clear text would
+// need some cipher letter to have 2 clear text letters.
+func wordKey(cipherWords, clearWords [][]byte) map[rune]rune {
+	if len(cipherWords) != len(clearWords) {
+		return nil
+	}
+	key := make(map[rune]rune)
+	for i := range cipherWords {
+		c := []rune(string(cipherWords[i]))
+		l := []rune(string(clearWords[i]))
+		if len(c) != len(l) {
+			return nil
+		}
+		for idx := range c {
+			clearLetter := foldCase(l[idx])
+			if prev, ok := key[c[idx]]; ok && prev != clearLetter {
+				return nil
+			}
+			key[c[idx]] = clearLetter
+		}
+	}
+	return key
 }
@@ -0,0 +1,132 @@
+package qp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePuzzleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		line int
+	}{
+		{"malformed hint", "qxf abc\nq=\n", 2},
+		{"hint letter not in puzzle", "# comment\nqxf abc\n\nz=e\n", 4},
+		{"contradicting hints", "qxf abc\nq=e\nq=t\n", 3},
+		{"same clear letter", "qxf abc\nq=e, x=e\n", 2},
+		{"hint and not hint", "q=e\nqxf abc\nq != e\n", 3},
+		{"trailing conjunction", "qxf abc\nq=e and\n", 2},
+		{"negative hint line", "qxf abc\ny does not equal q\n", 2},
+	}
+	for _, tt := range tests {
+		_, err := ParsePuzzle([]byte(tt.text))
+		var pe *ErrPuzzleParse
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: error %v, want an *ErrPuzzleParse", tt.name, err)
+			continue
+		}
+		if pe.Line != tt.line {
+			t.Errorf("%s: line %d, want %d", tt.name, pe.Line, tt.line)
+		}
+	}
+}
+
+func TestParsePuzzleHints(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("Qxf abc.\nIf Q equals T\nx doesn't equal a, b != c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(puzzle.Words) != 2 {
+		t.Errorf("%d words, want 2, the hint lines aren't cipher text", len(puzzle.Words))
+	}
+	if puzzle.Hints['q'] != 't' {
+		t.Errorf("hint q = %c, want t", puzzle.Hints['q'])
+	}
+	if string(puzzle.NotHints['x']) != "a" || string(puzzle.NotHints['b']) != "c" {
+		t.Errorf("not hints %q, want x != a, b != c", puzzle.NotHints)
+	}
+}
+
+func TestParsePuzzleEqualsWords(t *testing.T) {
+	// "equals" and "equal" can be cipher words
+	puzzle, err := ParsePuzzle([]byte("QXF EQUALS ABC.\nY DOESN'T EQUAL ZY\nq equals t\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	for _, word := range puzzle.Words {
+		words = append(words, string(word))
+	}
+	if want := []string{"qxf", "equals", "abc", "y", "doesn't", "equal", "zy"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("words %q, want %q", words, want)
+	}
+	if len(puzzle.Hints) != 1 || puzzle.Hints['q'] != 't' {
+		t.Errorf("hints %q, want q = t", puzzle.Hints)
+	}
+}
+
+func TestParsePuzzlesLines(t *testing.T) {
+	text := "# two puzzles\n---\nqxf abc\nq=t\n---\n\nzyx wvu\nz=e, z=t\n"
+	_, err := ParsePuzzles([]byte(text))
+	var pe *ErrPuzzleParse
+	if !errors.As(err, &pe) {
+		t.Fatalf("error %v, want an *ErrPuzzleParse", err)
+	}
+	if pe.Line != 8 {
+		t.Errorf("line %d, want 8", pe.Line)
+	}
+
+	puzzles, err := ParsePuzzles([]byte("# two puzzles\n---\nqxf abc\nq=t\n---\n\nzyx wvu\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(puzzles) != 2 {
+		t.Fatalf("%d puzzles, want 2", len(puzzles))
+	}
+	if puzzles[0].Line != 3 || puzzles[1].Line != 7 {
+		t.Errorf("puzzles at lines %d and %d, want 3 and 7", puzzles[0].Line, puzzles[1].Line)
+	}
+}
+
+func TestCipherWords(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"QXF'B ABC.", []string{"qxf'b", "abc"}},
+		{"'QXF' ABC", []string{"qxf", "abc"}},
+		{"‘QXF’ ABC’D", []string{"qxf", "abc'd"}},
+		{"QXFB' 'TIS", []string{"qxfb", "tis"}},
+		{"''QX''F'' ' ’", []string{"qx''f"}},
+		{"CGZZ-WPNCP (Y!)", []string{"cgzz", "wpncp", "y"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, word := range cipherWords([]byte(tt.line)) {
+			got = append(got, string(word))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cipherWords(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestDecipherPunctuation(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("\"'Qxf' Abc'd,\" QXF—ABC?\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	for _, word := range puzzle.Words {
+		words = append(words, string(word))
+	}
+	if want := []string{"qxf", "abc'd", "qxf", "abc"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("words %q, want %q", words, want)
+	}
+	key := map[rune]rune{'q': 't', 'x': 'h', 'f': 'e', 'a': 'y', 'b': 'o', 'c': 'u'}
+	if got, want := puzzle.Decipher(key), "\"'The' You'?,\" THE—YOU?"; got != want {
+		t.Errorf("Decipher = %q, want %q", got, want)
+	}
+}
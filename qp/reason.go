@@ -0,0 +1,197 @@
+package qp
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ReasonKind is the sort of deduction that gave a cipher letter
+// its clear text letter.
+type ReasonKind int
+
+const (
+	// HintReason is a hint from the puzzle
+	HintReason ReasonKind = iota
+	// NotEncipheredReason is for apostrophes and such, never enciphered
+	NotEncipheredReason
+	// IntersectionReason is the only clear letter left after intersecting
+	// the letters of same-shape dictionary words of Reason.Words
+	IntersectionReason
+	// SoleMatchReason is a cipher word whose pattern matched only
+	// a single dictionary word, Reason.Match
+	SoleMatchReason
+	// UnanimousReason is the only clear letter at Reason.Position of
+	// all Reason.Count dictionary words a cipher word's pattern matched
+	UnanimousReason
+	// RegexpLettersReason is the only clear letter left in the dictionary
+	// words that the patterns of the cipher words Reason.Words matched.
+	// The patterns used to be regular expressions, hence the name.
+	RegexpLettersReason
+	// BacktrackReason is the backtracking search choosing Reason.Match
+	// as the clear text of a cipher word
+	BacktrackReason
+	// EnumerationReason is the same clear letter in all Reason.Count
+	// complete keys enumerated
+	EnumerationReason
+	// FitnessReason is the best n-gram fitness of Reason.Count
+	// combinations of candidate letters, not a forced letter
+	FitnessReason
+	// HillClimbReason is the best key n-gram hill climbing found
+	HillClimbReason
+	// AssignedReason is someone solving by hand, see Assistant
+	AssignedReason
+	// HallSetReason is the only clear letter left once the cipher
+	// letters Reason.Letters take the clear letters Reason.Clear
+	HallSetReason
+	// HiddenSetReason is cipher letters Reason.Letters being the only
+	// ones that could be clear letters Reason.Clear, all of which
+	// have to solve some cipher letter
+	HiddenSetReason
+	// ArcConsistencyReason is Reason.Match being the only candidate of
+	// cipher word Reason.Words[0] that agrees with some candidate of each
+	// cipher word sharing its letters, see arcConsistency
+	ArcConsistencyReason
+)
+
+var reasonKindNames = []string{
+	HintReason:           "hint",
+	NotEncipheredReason:  "not enciphered",
+	IntersectionReason:   "shape intersection",
+	SoleMatchReason:      "sole match",
+	UnanimousReason:      "unanimous position",
+	RegexpLettersReason:  "pattern letters",
+	BacktrackReason:      "backtracking",
+	EnumerationReason:    "enumeration",
+	FitnessReason:        "n-gram fitness",
+	HillClimbReason:      "hill climbing",
+	AssignedReason:       "assigned",
+	HallSetReason:        "hall set",
+	HiddenSetReason:      "hidden set",
+	ArcConsistencyReason: "arc consistency",
+}
+
+func (k ReasonKind) String() string {
+	if k < 0 || int(k) >= len(reasonKindNames) {
+		return fmt.Sprintf("ReasonKind(%d)", int(k))
+	}
+	return reasonKindNames[k]
+}
+
+// Reason justifies associating a clear text letter with a cipher letter.
+// Which fields mean anything depends on Kind.
+type Reason struct {
+	Kind     ReasonKind
+	Words    []string // cipher words the deduction came from
+	Match    string   // the clear text word chosen for Words[0]
+	Pattern  string   // clear letters allowed at each position of Words[0]
+	Position int      // letter position in Words[0], UnanimousReason
+	Count    int      // number of dictionary matches, keys or combinations
+	N        int      // n-gram length, FitnessReason and HillClimbReason
+	Letters  string   // cipher letters, HallSetReason and HiddenSetReason
+	Clear    string   // clear letters of Letters, HallSetReason and HiddenSetReason
+}
+
+func (r Reason) String() string {
+	switch r.Kind {
+	case HintReason:
+		return "hint"
+	case NotEncipheredReason:
+		return "not a letter, never enciphered"
+	case IntersectionReason:
+		return fmt.Sprintf("only clear letter left after intersecting same-shape words of %s", quoteWords(r.Words))
+	case SoleMatchReason:
+		return fmt.Sprintf("sole dictionary match %q of %s, pattern %s", r.Match, quoteWords(r.Words), r.Pattern)
+	case UnanimousReason:
+		return fmt.Sprintf("only clear letter at position %d of all %d dictionary matches of %s, pattern %s",
+			r.Position, r.Count, quoteWords(r.Words), r.Pattern,
+		)
+	case RegexpLettersReason:
+		return fmt.Sprintf("only clear letter in dictionary matches of %s", quoteWords(r.Words))
+	case BacktrackReason:
+		return fmt.Sprintf("backtracking choice of %q for %s", r.Match, quoteWords(r.Words))
+	case EnumerationReason:
+		return fmt.Sprintf("same clear letter in all %d solutions", r.Count)
+	case FitnessReason:
+		return fmt.Sprintf("best %d-gram fitness of %d combinations, not forced", r.N, r.Count)
+	case HillClimbReason:
+		return fmt.Sprintf("%d-gram hill climbing", r.N)
+	case AssignedReason:
+		return "assigned by hand"
+	case HallSetReason:
+		return fmt.Sprintf("only clear letter left after %s took %s",
+			listLetters("cipher letter", r.Letters), listLetters("clear letter", r.Clear),
+		)
+	case ArcConsistencyReason:
+		return fmt.Sprintf("only dictionary word %q of %s agreeing with the candidates of every cipher word sharing its letters",
+			r.Match, quoteWords(r.Words),
+		)
+	case HiddenSetReason:
+		return fmt.Sprintf("only %s could be %s, and every candidate clear letter gets used",
+			listLetters("cipher letter", r.Letters), listLetters("clear letter", r.Clear),
+		)
+	}
+	return r.Kind.String()
+}
+
+// listLetters lists letters after noun, made plural for more
+// than one letter: "cipher letters x, y".
+func listLetters(noun, letters string) string {
+	var list []string
+	for _, l := range letters {
+		list = append(list, string(l))
+	}
+	if len(list) > 1 {
+		noun += "s"
+	}
+	return noun + " " + strings.Join(list, ", ")
+}
+
+func quoteWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = fmt.Sprintf("%q", word)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Explain writes r.Deductions as a chain, a line per deduction in the
+// order they got made. Consecutive letters with the same reason, all
+// the letters of a sole dictionary match say, share a line. Each line
+// ends with the letters of its cipher words that earlier lines solved.
+func (r *Result) Explain(w io.Writer) {
+	known := make(map[rune]rune)
+	step := 0
+	for i := 0; i < len(r.Deductions); {
+		reason := r.Deductions[i].Reason
+		j := i + 1
+		for j < len(r.Deductions) && r.Deductions[j].Reason.String() == reason.String() {
+			j++
+		}
+		var letters []string
+		for _, a := range r.Deductions[i:j] {
+			letters = append(letters, fmt.Sprintf("%c = %c", a.CipherLetter, a.ClearLetter))
+		}
+		var given []string
+		seen := make(map[rune]bool)
+		for _, word := range reason.Words {
+			for _, c := range word {
+				if l, ok := known[c]; ok && !seen[c] && unicode.IsLetter(c) {
+					given = append(given, fmt.Sprintf("%c = %c", c, l))
+					seen[c] = true
+				}
+			}
+		}
+		step++
+		fmt.Fprintf(w, "%2d. %s: %v", step, strings.Join(letters, ", "), reason)
+		if len(given) > 0 {
+			fmt.Fprintf(w, "; given %s", strings.Join(given, ", "))
+		}
+		fmt.Fprintln(w)
+		for _, a := range r.Deductions[i:j] {
+			known[a.CipherLetter] = a.ClearLetter
+		}
+		i = j
+	}
+}
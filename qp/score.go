@@ -0,0 +1,73 @@
+package qp
+
+import "math"
+
+// letterFrequencies holds the percentage of English text
+// made up of each letter.
+var letterFrequencies = map[rune]float64{
+	'a': 8.2, 'b': 1.5, 'c': 2.8, 'd': 4.3, 'e': 12.7, 'f': 2.2,
+	'g': 2.0, 'h': 6.1, 'i': 7.0, 'j': 0.15, 'k': 0.77, 'l': 4.0,
+	'm': 2.4, 'n': 6.7, 'o': 7.5, 'p': 1.9, 'q': 0.095, 'r': 6.0,
+	's': 6.3, 't': 9.1, 'u': 2.8, 'v': 0.98, 'w': 2.4, 'x': 0.15,
+	'y': 2.0, 'z': 0.074,
+}
+
+// wordScore sums the log of the smoothed relative frequency of every
+// word in words, according to counts, which sum to total. Words not in
+// counts get a count of zero, so rare and unknown words drag down a score.
+func wordScore(words []string, counts map[string]int, total int) float64 {
+	denominator := float64(total + len(counts))
+	score := 0.0
+	for _, word := range words {
+		score += math.Log(float64(counts[word]+1) / denominator)
+	}
+	return score
+}
+
+// dictLetterFrequencies works out the percentage of the letters of the
+// words of shapeDict that each letter of alphabet ab makes up, for
+// languages that letterFrequencies doesn't cover.
+func dictLetterFrequencies(ab *Alphabet, shapeDict map[string][]string) map[rune]float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, words := range shapeDict {
+		for _, word := range words {
+			for _, r := range word {
+				if _, ok := ab.bit(r); ok && r != '\'' {
+					counts[r]++
+					total++
+				}
+			}
+		}
+	}
+	frequencies := make(map[rune]float64)
+	for r, n := range counts {
+		frequencies[r] = 100. * float64(n) / float64(total)
+	}
+	return frequencies
+}
+
+// letterScore sums the log of the frequency of every letter in words,
+// according to frequencies, letterFrequencies for English. Higher (less
+// negative) scores look more like the language's text. Letters it
+// doesn't know, apostrophes and '?', don't count.
+func letterScore(words []string, frequencies map[rune]float64) float64 {
+	score := 0.0
+	for _, word := range words {
+		for _, r := range word {
+			if f, ok := frequencies[r]; ok {
+				score += math.Log(f / 100.)
+			}
+		}
+	}
+	return score
+}
+
+// countsTotal sums all the word counts.
+func countsTotal(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,249 @@
+package qp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// search holds the state of a backtracking search for a key that
+// deciphers every puzzle word into a dictionary word of the same shape.
+type search struct {
+	words      [][]rune            // unique cipher words
+	shapes     []string            // configuration of each of words
+	shapeDict  map[string][]string // clear text words by configuration
+	solved     *Solved
+	encodeSelf bool
+	nodes      int             // number of choices tried
+	limit      int             // stop after finding this many keys
+	keys       []map[rune]rune // complete keys found
+	ctx        context.Context // search gives up when done, if not nil
+}
+
+// newSearch sets up a search starting from the letters already solved.
+// The cipher words go in alphabetical order, so that the search breaks
+// ties between words the same way every time.
+func newSearch(solved *Solved, shapeDict map[string][]string, puzzlewords [][]byte, encodeSelf bool, limit int) *search {
+	s := &search{
+		shapeDict:  shapeDict,
+		solved:     solved,
+		encodeSelf: encodeSelf,
+		limit:      limit,
+	}
+	seenWordAlready := make(map[string]bool)
+	for _, word := range puzzlewords {
+		if seenWordAlready[string(word)] {
+			continue
+		}
+		seenWordAlready[string(word)] = true
+		s.words = append(s.words, []rune(string(word)))
+	}
+	sort.Slice(s.words, func(i, j int) bool { return string(s.words[i]) < string(s.words[j]) })
+	for _, word := range s.words {
+		s.shapes = append(s.shapes, StringConfiguration(string(word)))
+	}
+	return s
+}
+
+// fits reports whether clear text word clear could be the solution of
+// cipher word cipher, given the letters in the key so far. The two words
+// have the same shape, so repeated letters take care of themselves.
+func (s *search) fits(cipher []rune, clear string) bool {
+	idx := 0
+	for _, p := range clear {
+		c := cipher[idx]
+		idx++
+		if k, ok := s.solved.SolvedLetters[c]; ok {
+			if k != p {
+				return false
+			}
+			continue
+		}
+		if s.solved.Alphabet.Has(s.solved.ClearLetters, p) {
+			return false
+		}
+		if !s.encodeSelf && c == p {
+			return false
+		}
+		if s.solved.Alphabet.Has(s.solved.Excluded[c], p) {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the clear text words that could still be the
+// solution of cipher word n.
+func (s *search) candidates(n int) []string {
+	var fitting []string
+	for _, clear := range s.shapeDict[s.shapes[n]] {
+		if s.fits(s.words[n], clear) {
+			fitting = append(fitting, clear)
+		}
+	}
+	return fitting
+}
+
+// complete reports whether every letter of cipher word n has a clear text letter.
+func (s *search) complete(n int) bool {
+	for _, c := range s.words[n] {
+		if _, ok := s.solved.SolvedLetters[c]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// assign marks the letters of clear text word clear as the solutions
+// of cipher word n's letters. Rolling back to the returned snapshot
+// undoes it.
+func (s *search) assign(n int, clear string) int {
+	snapshot := s.solved.Snapshot()
+	reason := Reason{Kind: BacktrackReason, Words: []string{string(s.words[n])}, Match: clear}
+	idx := 0
+	for _, p := range clear {
+		// fits() already checked for conflicts
+		s.solved.SetSolved(s.words[n][idx], p, reason)
+		idx++
+	}
+	return snapshot
+}
+
+// solve branches on the incomplete cipher word with the fewest
+// remaining candidate clear text words, recursing after trying each
+// candidate, and undoing the candidate's letters on a contradiction.
+// It keeps each key that deciphers all of the cipher words into
+// dictionary words, and returns true, leaving the letters of the last
+// key solved, on finding s.limit keys, or on s.ctx getting done.
+func (s *search) solve(depth int) bool {
+	if s.ctx != nil && s.ctx.Err() != nil {
+		// out of time, unwind as if reaching the limit
+		return true
+	}
+	branch := -1
+	var branchCandidates []string
+	for n := range s.words {
+		if s.complete(n) {
+			// complete words still have to be dictionary words
+			if len(s.candidates(n)) == 0 {
+				return false
+			}
+			continue
+		}
+		fitting := s.candidates(n)
+		if len(fitting) == 0 {
+			// some cipher word can't be any dictionary word
+			return false
+		}
+		if branch < 0 || len(fitting) < len(branchCandidates) {
+			branch = n
+			branchCandidates = fitting
+		}
+	}
+	if branch < 0 {
+		key := make(map[rune]rune)
+		for _, cipherLetter := range s.solved.CipherLetters {
+			// cipher letters only in words missing from the
+			// dictionary don't get a clear text letter
+			if clearLetter, ok := s.solved.SolvedLetters[cipherLetter]; ok {
+				key[cipherLetter] = clearLetter
+			}
+		}
+		s.keys = append(s.keys, key)
+		return len(s.keys) >= s.limit
+	}
+
+	for _, clear := range branchCandidates {
+		s.nodes++
+		if s.solved.Verbose {
+			fmt.Fprintf(s.solved.out(), "%*sdepth %d trying %q as %q, 1 of %d candidates\n",
+				depth, "", depth, string(s.words[branch]), clear, len(branchCandidates),
+			)
+		}
+		snapshot := s.assign(branch, clear)
+		if s.solve(depth + 1) {
+			return true
+		}
+		s.solved.Rollback(snapshot)
+	}
+	return false
+}
+
+// backtrack searches for a key consistent with the letters already
+// solved and the cycles' shape dictionary, leaving the letters of any
+// key it finds solved. It reports whether it found a key. It looks for
+// a second key too: when there is one, the key it leaves is a guess, and
+// it returns the alphabetized cipher letters the two keys disagree on.
+func backtrack(ctx context.Context, solved *Solved, shapeDict map[string][]string, puzzlewords [][]byte, encodeSelf bool) (bool, []rune) {
+	w := solved.out()
+	s := newSearch(solved, shapeDict, puzzlewords, encodeSelf, 2)
+	s.ctx = ctx
+
+	fmt.Fprintf(w, "---start backtracking search---\n\n")
+	s.solve(0)
+	fmt.Fprintf(w, "backtracking search tried %d choices\n", s.nodes)
+	if ctx.Err() != nil {
+		fmt.Fprintf(w, "backtracking search ran out of time\n")
+		return false, nil
+	}
+	switch len(s.keys) {
+	case 0:
+		fmt.Fprintf(w, "backtracking search found no key\n")
+		return false, nil
+	case 1:
+		// Not finding a second key undid the first one's letters.
+		// Finding it again puts them back, with their reasons.
+		s = newSearch(solved, shapeDict, puzzlewords, encodeSelf, 1)
+		s.ctx = ctx
+		s.solve(0)
+		return true, nil
+	}
+	var ambiguous []rune
+	for _, cipherLetter := range solved.CipherLetters {
+		if s.keys[0][cipherLetter] != s.keys[1][cipherLetter] {
+			ambiguous = append(ambiguous, cipherLetter)
+		}
+	}
+	fmt.Fprintf(w, "backtracking search found more than one key, guessing cipher letters %q\n", string(ambiguous))
+	return true, ambiguous
+}
+
+// enumerate searches for up to limit keys consistent with the letters
+// already solved and the cycles' shape dictionary. If it finds all of
+// the keys without reaching limit, it marks as solved any cipher letters
+// that have the same clear text letter in every key.
+func enumerate(ctx context.Context, solved *Solved, shapeDict map[string][]string, puzzlewords [][]byte, encodeSelf bool, limit int) []map[rune]rune {
+	w := solved.out()
+	s := newSearch(solved, shapeDict, puzzlewords, encodeSelf, limit)
+	s.ctx = ctx
+
+	fmt.Fprintf(w, "---start enumerating solutions---\n\n")
+	snapshot := solved.Snapshot()
+	if s.solve(0) {
+		// reached limit or ran out of time, there could be more keys
+		solved.Rollback(snapshot)
+		fmt.Fprintf(w, "enumeration tried %d choices, stopped at %d keys\n", s.nodes, len(s.keys))
+		return s.keys
+	}
+	fmt.Fprintf(w, "enumeration tried %d choices, found all %d keys\n", s.nodes, len(s.keys))
+
+	if len(s.keys) == 0 {
+		return nil
+	}
+	for _, cipherLetter := range solved.CipherLetters {
+		if _, ok := solved.SolvedLetters[cipherLetter]; ok {
+			continue
+		}
+		clearLetter, unanimous := s.keys[0][cipherLetter]
+		for _, key := range s.keys[1:] {
+			if key[cipherLetter] != clearLetter {
+				unanimous = false
+				break
+			}
+		}
+		if unanimous {
+			solved.mark(cipherLetter, clearLetter, Reason{Kind: EnumerationReason, Count: len(s.keys)})
+		}
+	}
+	return s.keys
+}
@@ -0,0 +1,115 @@
+package qp
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testShapeDict makes a shape dictionary of words, as ReadDictionary would.
+func testShapeDict(words ...string) map[string][]string {
+	shapeDict := make(map[string][]string)
+	for _, word := range words {
+		configuration := StringConfiguration(word)
+		shapeDict[configuration] = append(shapeDict[configuration], word)
+	}
+	return shapeDict
+}
+
+func TestSolveBacktrack(t *testing.T) {
+	// Every pair of neighboring words has clear text words that agree
+	// on their shared letter, "on" "no" "on" among them, so the cycles
+	// solve nothing. Only "no" "oh" "he" has four different letters.
+	puzzle, err := ParsePuzzle([]byte("xy yz zw\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := &Solver{ShapeDict: testShapeDict("no", "oh", "ox", "be", "he", "on")}
+
+	result, err := solver.Solve(puzzle, Options{Cycles: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(result.Unsolved); got != "wxyz" {
+		t.Errorf("without backtracking, unsolved %q, want %q", got, "wxyz")
+	}
+
+	result, err = solver.Solve(puzzle, Options{Cycles: 8, Backtrack: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"no", "oh", "he"}; !reflect.DeepEqual(result.Words, want) {
+		t.Errorf("with backtracking, words %q, want %q", result.Words, want)
+	}
+	if len(result.Unsolved) > 0 || len(result.Guessed) > 0 {
+		t.Errorf("with backtracking, unsolved %q, guessed %q, want none", string(result.Unsolved), string(result.Guessed))
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("xy yz\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shapeDict := testShapeDict("to", "on", "no", "at", "so")
+	tests := []struct {
+		name       string
+		hints      map[rune]rune
+		limit      int
+		want       []string // deciphered puzzle words of each key
+		wantSolved map[rune]rune
+	}{
+		// "no" "on" and "on" "no" decipher x and z to the same letter
+		{"all keys", nil, 10, []string{"at to", "so on", "to on"}, map[rune]rune{}},
+		{"limit", nil, 2, nil, map[rune]rune{}},
+		{"unanimous", map[rune]rune{'y': 'o'}, 10, []string{"so on", "to on"}, map[rune]rune{'y': 'o', 'z': 'n'}},
+	}
+	for _, tt := range tests {
+		solved := &Solved{SolvedLetters: make(map[rune]rune), CipherLetters: puzzle.CipherLetters}
+		for cipherLetter, clearLetter := range tt.hints {
+			if err := solved.SetSolved(cipherLetter, clearLetter, Reason{Kind: HintReason}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		keys := enumerate(context.Background(), solved, shapeDict, puzzle.UniqueWords, false, tt.limit)
+		var got []string
+		for _, key := range keys {
+			got = append(got, clearWord(puzzle.Words[0], key)+" "+clearWord(puzzle.Words[1], key))
+		}
+		sort.Strings(got)
+		if tt.want == nil {
+			if len(got) != tt.limit {
+				t.Errorf("%s: found %d keys, want %d", tt.name, len(got), tt.limit)
+			}
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: found %q, want %q", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(solved.SolvedLetters, tt.wantSolved) {
+			t.Errorf("%s: solved %q, want %q", tt.name, solved.SolvedLetters, tt.wantSolved)
+		}
+	}
+}
+
+func TestSolveSolutionsRanked(t *testing.T) {
+	puzzle, err := ParsePuzzle([]byte("xy yz\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver := &Solver{
+		ShapeDict: testShapeDict("to", "on", "no", "at", "so"),
+		Counts:    map[string]int{"so": 100, "on": 50, "to": 20, "at": 10, "no": 5},
+	}
+	result, err := solver.Solve(puzzle, Options{Cycles: 8, Solutions: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, solution := range result.Solutions {
+		got = append(got, solution.Words)
+	}
+	want := [][]string{{"so", "on"}, {"to", "on"}, {"at", "to"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("solutions %q, want %q, most common words first", got, want)
+	}
+}
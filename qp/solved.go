@@ -1,37 +1,199 @@
 package qp
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+)
 
 // Solved holds information about cipher letters and their solutions
 type Solved struct {
 	CipherLetters []rune        // alphabetized slice of cipherletters
 	SolvedLetters map[rune]rune // cipherletter key to clear text letter value
-	ClearLetters  map[rune]bool // all the clear letters so far
+	ClearLetters  LetterSet     // all the clear letters so far
+	Alphabet      *Alphabet     // clear text letters, English if nil
+	Trail         []Assignment  // solved letters in the order they got solved
 	Verbose       bool
+	Out           io.Writer // verbose and problem output, discarded if nil
+
+	// Excluded has the clear text letters each cipher letter can't be,
+	// from "x!=e" hints, see Puzzle.NotHints.
+	Excluded map[rune]LetterSet
+
+	// Problems has what didn't add up while solving, once each:
+	// deductions that conflicted with solved letters, as *ErrConflict,
+	// and cipher letters or words left without candidates.
+	Problems []error
+}
+
+// Assignment is a single cipher letter, clear text letter association,
+// and why it got made.
+type Assignment struct {
+	CipherLetter rune
+	ClearLetter  rune
+	Reason       Reason
+}
+
+// ErrConflict is the error when associating a clear text letter to a
+// cipher letter contradicts an earlier association.
+type ErrConflict struct {
+	CipherLetter rune
+	ClearLetter  rune
+	Reason       Reason
+	Previous     Assignment // the earlier, contradicted, association
+}
+
+func (e *ErrConflict) Error() string {
+	if e.Previous.CipherLetter == e.CipherLetter {
+		return fmt.Sprintf("setting cipher letter %c to clear letter %c (%s), already had a clear letter %c (%s)",
+			e.CipherLetter, e.ClearLetter, e.Reason, e.Previous.ClearLetter, e.Previous.Reason,
+		)
+	}
+	return fmt.Sprintf("cipher letter %c proposed solution %c (%s), %c already a solution of %c (%s)",
+		e.CipherLetter, e.ClearLetter, e.Reason, e.ClearLetter, e.Previous.CipherLetter, e.Previous.Reason,
+	)
 }
 
 // SetSolved associates a clear text letter to a cipher text letter.
 // It will reject associating a 2nd, different, clear text letter
-// to a cipher letter. You can associate a particular clear text
-// letter to a cipher letter repeatedly without problems.
-func (s *Solved) SetSolved(cipherLetter, clearLetter rune) {
+// to a cipher letter, or a clear text letter already associated with
+// a different cipher letter, returning an *ErrConflict.
+// You can associate a particular clear text letter to a cipher letter
+// repeatedly without problems.
+func (s *Solved) SetSolved(cipherLetter, clearLetter rune, reason Reason) error {
 	if prevClear, ok := s.SolvedLetters[cipherLetter]; ok {
 		if clearLetter == prevClear {
 			// Already had this as a solved letter pair
-			return
+			return nil
+		}
+		return &ErrConflict{
+			CipherLetter: cipherLetter,
+			ClearLetter:  clearLetter,
+			Reason:       reason,
+			Previous:     s.assignment(cipherLetter),
 		}
-		fmt.Printf("PROBLEM: setting cipher letter %c to clear letter %c, already had a clear letter %c\n",
-			cipherLetter, clearLetter, prevClear,
-		)
-		return
 	}
-	if s.ClearLetters[clearLetter] {
-		fmt.Printf("PROBLEM: cipher letter %c proposed solution %c, %c already a solution\n", cipherLetter, clearLetter, clearLetter)
-		return
+	if s.clearUsed(clearLetter) {
+		var prevCipher rune
+		for cl, sl := range s.SolvedLetters {
+			if sl == clearLetter {
+				prevCipher = cl
+			}
+		}
+		return &ErrConflict{
+			CipherLetter: cipherLetter,
+			ClearLetter:  clearLetter,
+			Reason:       reason,
+			Previous:     s.assignment(prevCipher),
+		}
 	}
 	s.SolvedLetters[cipherLetter] = clearLetter
-	s.ClearLetters[clearLetter] = true
+	s.ClearLetters = s.Alphabet.Add(s.ClearLetters, clearLetter)
+	s.Trail = append(s.Trail, Assignment{
+		CipherLetter: cipherLetter,
+		ClearLetter:  clearLetter,
+		Reason:       reason,
+	})
 	if s.Verbose {
-		fmt.Printf("\tcipher letter %c solved as %c\n", cipherLetter, clearLetter)
+		fmt.Fprintf(s.out(), "\tcipher letter %c solved as %c\n", cipherLetter, clearLetter)
+	}
+	return nil
+}
+
+// Snapshot marks the current state of the solved letters,
+// so that Rollback can return to it.
+func (s *Solved) Snapshot() int {
+	return len(s.Trail)
+}
+
+// Rollback retracts all of the solved letters associated
+// after the corresponding call to Snapshot.
+func (s *Solved) Rollback(snapshot int) {
+	for i := len(s.Trail) - 1; i >= snapshot; i-- {
+		a := s.Trail[i]
+		delete(s.SolvedLetters, a.CipherLetter)
+		s.ClearLetters = s.Alphabet.Remove(s.ClearLetters, a.ClearLetter)
+		if s.Verbose {
+			fmt.Fprintf(s.out(), "\tcipher letter %c no longer solved as %c\n", a.CipherLetter, a.ClearLetter)
+		}
+	}
+	s.Trail = s.Trail[:snapshot]
+}
+
+// Complete reports whether all of the cipher letters have clear text letters.
+// SolvedLetters can have letters, like the apostrophe, that aren't in
+// CipherLetters, so it's not just a matter of counting.
+func (s *Solved) Complete() bool {
+	for _, cipherLetter := range s.CipherLetters {
+		if _, ok := s.SolvedLetters[cipherLetter]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// exclude rules out the clear text letters of notHints, cipher letter
+// to clear text letters, as solutions of their cipher letters.
+func (s *Solved) exclude(notHints map[rune][]rune) {
+	for cipherLetter, clearLetters := range notHints {
+		for _, clearLetter := range clearLetters {
+			clearLetter = s.Alphabet.NormalizeLetter(clearLetter)
+			if s.Excluded == nil {
+				s.Excluded = make(map[rune]LetterSet)
+			}
+			s.Excluded[cipherLetter] = s.Alphabet.Add(s.Excluded[cipherLetter], clearLetter)
+			fmt.Fprintf(s.out(), "Hint: %c != %c\n\n", cipherLetter, clearLetter)
+		}
+	}
+}
+
+// mark calls SetSolved, recording in Problems rather than returning
+// any conflict. A conflicting association doesn't get made.
+func (s *Solved) mark(cipherLetter, clearLetter rune, reason Reason) {
+	if err := s.SetSolved(cipherLetter, clearLetter, reason); err != nil {
+		s.problem(err)
+	}
+}
+
+// problem prints err, and adds it to Problems unless it's there already,
+// as the same conflict can come up cycle after cycle.
+func (s *Solved) problem(err error) {
+	fmt.Fprintf(s.out(), "PROBLEM: %v\n", err)
+	for _, p := range s.Problems {
+		if p.Error() == err.Error() {
+			return
+		}
+	}
+	s.Problems = append(s.Problems, err)
+}
+
+// clearUsed reports whether clearLetter is already the solution of some
+// cipher letter. ClearLetters can't hold every rune someone might
+// use as a hint, so it looks through SolvedLetters for the rest.
+func (s *Solved) clearUsed(clearLetter rune) bool {
+	if _, ok := s.Alphabet.bit(clearLetter); ok {
+		return s.Alphabet.Has(s.ClearLetters, clearLetter)
+	}
+	for _, sl := range s.SolvedLetters {
+		if sl == clearLetter {
+			return true
+		}
+	}
+	return false
+}
+
+// assignment finds the Assignment that solved cipherLetter.
+func (s *Solved) assignment(cipherLetter rune) Assignment {
+	for _, a := range s.Trail {
+		if a.CipherLetter == cipherLetter {
+			return a
+		}
+	}
+	return Assignment{CipherLetter: cipherLetter, ClearLetter: s.SolvedLetters[cipherLetter]}
+}
+
+func (s *Solved) out() io.Writer {
+	if s.Out == nil {
+		return io.Discard
 	}
+	return s.Out
 }
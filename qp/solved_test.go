@@ -0,0 +1,60 @@
+package qp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRollbackAfterConflict(t *testing.T) {
+	tests := []struct {
+		name         string
+		cipher       rune
+		clear        rune
+		wantPrevious rune // cipher letter of the contradicted assignment
+	}{
+		{"cipher letter already solved", 'x', 'a', 'x'},
+		{"clear letter already used", 'z', 't', 'q'},
+	}
+	for _, tt := range tests {
+		solved := &Solved{SolvedLetters: make(map[rune]rune)}
+		if err := solved.SetSolved('q', 't', Reason{Kind: HintReason}); err != nil {
+			t.Fatal(err)
+		}
+		snapshot := solved.Snapshot()
+		wantLetters := map[rune]rune{'q': 't'}
+		wantClear := solved.ClearLetters
+		wantTrail := append([]Assignment(nil), solved.Trail...)
+
+		reason := Reason{Kind: BacktrackReason}
+		if err := solved.SetSolved('x', 'h', reason); err != nil {
+			t.Fatal(err)
+		}
+		if err := solved.SetSolved('f', 'e', reason); err != nil {
+			t.Fatal(err)
+		}
+		err := solved.SetSolved(tt.cipher, tt.clear, reason)
+		var conflict *ErrConflict
+		if !errors.As(err, &conflict) {
+			t.Fatalf("%s: error %v, want an *ErrConflict", tt.name, err)
+		}
+		if conflict.Previous.CipherLetter != tt.wantPrevious {
+			t.Errorf("%s: contradicted cipher letter %c, want %c", tt.name, conflict.Previous.CipherLetter, tt.wantPrevious)
+		}
+
+		solved.Rollback(snapshot)
+		if !reflect.DeepEqual(solved.SolvedLetters, wantLetters) {
+			t.Errorf("%s: solved letters %q after rollback, want %q", tt.name, solved.SolvedLetters, wantLetters)
+		}
+		if solved.ClearLetters != wantClear {
+			t.Errorf("%s: clear letters %v after rollback, want %v", tt.name, solved.ClearLetters, wantClear)
+		}
+		if !reflect.DeepEqual(solved.Trail, wantTrail) {
+			t.Errorf("%s: trail %v after rollback, want %v", tt.name, solved.Trail, wantTrail)
+		}
+		// the rolled back clear letters are free again
+		if err := solved.SetSolved('z', 'h', reason); err != nil {
+			t.Errorf("%s: after rollback: %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,681 @@
+package qp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Puzzle holds the cipher text words and hints of a single Cryptoquip.
+type Puzzle struct {
+	Words         [][]byte      // cipher words in puzzle order
+	UniqueWords   [][]byte      // each different cipher word once, alphabetized
+	CipherLetters []rune        // alphabetized slice of cipher letters
+	Hints         map[rune]rune // cipher letter key to clear text letter value
+
+	// NotHints has the clear text letters each cipher letter isn't,
+	// from "x!=e" hints, see AddNotHint.
+	NotHints map[rune][]rune
+
+	// Known is the key of the puzzle's known solution, cipher letter to
+	// clear text letter, if its puzzle file has one, see ParsePuzzle.
+	Known map[rune]rune
+	// Line is the line of the puzzle file its cipher words start on.
+	Line int
+	// Original is the puzzle's lines of cipher text as the puzzle file
+	// has them, with punctuation and upper case letters, see Decipher.
+	Original string
+}
+
+// Decipher replaces the cipher letters of the puzzle's Original text
+// with their clear text letters from key, '?' for cipher letters key
+// doesn't have. Upper case cipher letters get upper case clear text
+// letters, and everything else stays the way it was. Puzzles without
+// Original text, made by hand rather than by ParsePuzzle, decipher
+// as their words, separated by spaces.
+func (p *Puzzle) Decipher(key map[rune]rune) string {
+	if p.Original == "" {
+		var words []string
+		for _, word := range p.Words {
+			words = append(words, clearWord(word, key))
+		}
+		return strings.Join(words, " ")
+	}
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) {
+			return r
+		}
+		clear, ok := key[foldCase(r)]
+		if !ok {
+			return '?'
+		}
+		if unicode.IsUpper(r) {
+			return unicode.ToUpper(clear)
+		}
+		return clear
+	}, p.Original)
+}
+
+// Strategy is a way for Solver.Solve to go about solving a puzzle
+type Strategy int
+
+const (
+	// ShapeStrategy cycles through intersecting the letters of
+	// same-shape dictionary words. It needs word breaks.
+	ShapeStrategy Strategy = iota
+	// NgramStrategy hill climbs over keys, scoring the clear text
+	// with letter n-gram statistics, see Solver.Ngrams. It ignores
+	// word breaks, so it works on Patristocrats.
+	NgramStrategy
+)
+
+// Options control a single call to Solver.Solve
+type Options struct {
+	Strategy   Strategy
+	Cycles     int       // maximum number of cycles to attempt
+	EncodeSelf bool      // cipher letters can encode themselves
+	Backtrack  bool      // search for a key when the cycles stop making progress
+	Solutions  int       // enumerate up to this many complete keys, if more than 0
+	Missing    int       // treat up to this many cipher words as not in the dictionary
+	Hybrid     bool      // choose letters the cycles leave unsolved by n-gram fitness
+	Restarts   int       // number of random keys to hill climb from, NgramStrategy
+	Seed       int64     // random number seed for hill climbing, NgramStrategy
+	Verbose    bool      // very verbose output
+	Out        io.Writer // progress output, discarded if nil
+}
+
+// Result is what Solver.Solve found out about a Puzzle.
+type Result struct {
+	Key       map[rune]rune // cipher letter key to clear text letter value
+	Words     []string      // clear text of puzzle words, '?' for unsolved letters
+	ClearText string        // the puzzle's Original text deciphered, see Puzzle.Decipher
+	Solved    []rune        // alphabetized cipher letters with a clear text letter
+	Guessed   []rune        // alphabetized cipher letters n-gram fitness chose, see Options.Hybrid, or backtracking chose from more than one key
+	Unsolved  []rune        // alphabetized cipher letters without a clear text letter
+	Cycles    int           // number of cycles run, or hill climbing restarts
+
+	// Solutions holds the complete keys found by Options.Solutions,
+	// best ranked first.
+	Solutions []*Solution
+
+	// Missing holds the cipher words treated as missing from the
+	// dictionary, see Options.Missing.
+	Missing []MissingWord
+
+	// Candidates holds the alphabetized clear text letters each cipher
+	// letter could still be: the solved letter, or what the last cycle
+	// left for unsolved letters.
+	Candidates map[rune][]rune
+
+	// WordCandidates counts the same-shape dictionary words that each
+	// unique cipher word could still be, given the key.
+	WordCandidates map[string]int
+
+	// Deductions holds the cipher letters of Key in the order they
+	// got their clear text letters, and why, see Result.Explain.
+	Deductions []Assignment
+
+	// Problems holds what didn't add up while solving, see
+	// Solved.Problems. Letters the problems were about can be wrong,
+	// or the dictionary can be missing a word.
+	Problems []error
+}
+
+// MissingWord is a cipher word that Solver.Solve assumed isn't in the
+// dictionary, a proper noun say, and so didn't use to find letters.
+type MissingWord struct {
+	CipherWord string `json:"cipher_word"`
+	ClearWord  string `json:"clear_word"` // from letters solved in other words, '?' for the rest
+}
+
+// Solution is a complete key that deciphers every puzzle word
+// into a same-shape dictionary word.
+type Solution struct {
+	Key   map[rune]rune // cipher letter key to clear text letter value
+	Words []string      // clear text of puzzle words
+	Score float64       // higher scores rank first
+}
+
+// Complete reports whether every cipher letter got a clear text letter.
+func (r *Result) Complete() bool {
+	return len(r.Unsolved) == 0
+}
+
+// Solver finds clear text for Cryptoquips using a shape dictionary
+// composed from a clear text dictionary, see NewShapeDict.
+// A single Solver can solve any number of puzzles.
+type Solver struct {
+	ShapeDict map[string][]string
+	// Counts are optional word frequencies from a dictionary, see
+	// ReadDictionary. With Counts, complete keys rank by how common
+	// the words they decipher to are, rather than by letter frequency.
+	Counts map[string]int
+	// Ngrams are letter n-gram statistics, needed by NgramStrategy
+	// and Options.Hybrid
+	Ngrams *NgramModel
+	// Alphabet is the clear text letters of ShapeDict's words, see
+	// Dictionary.Alphabet. Nil means English.
+	Alphabet *Alphabet
+}
+
+// Solve cycles through the steps of finding clear text letters for
+// the cipher text letters of puzzle. A puzzle the cycles can't make
+// sense of gets an error, an *ErrNoCandidates for example, rather than
+// a Result.
+func (s *Solver) Solve(puzzle *Puzzle, opts Options) (*Result, error) {
+	return s.SolveContext(context.Background(), puzzle, opts)
+}
+
+// SolveContext is Solve, giving up with ctx's error when ctx is done,
+// which is how to limit the time a puzzle gets.
+func (s *Solver) SolveContext(ctx context.Context, puzzle *Puzzle, opts Options) (*Result, error) {
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+	w := opts.Out
+	if err := puzzle.checkSelfHints(s.Alphabet, opts.EncodeSelf); err != nil {
+		return nil, err
+	}
+
+	if opts.Strategy == NgramStrategy {
+		return s.solveNgrams(ctx, puzzle, opts)
+	}
+
+	solved := &Solved{
+		SolvedLetters: make(map[rune]rune),
+		CipherLetters: puzzle.CipherLetters,
+		Alphabet:      s.Alphabet,
+		Verbose:       opts.Verbose,
+		Out:           w,
+	}
+	for cipherHint, clearHint := range puzzle.Hints {
+		clearHint = s.Alphabet.NormalizeLetter(clearHint)
+		fmt.Fprintf(w, "Hint: %c = %c\n\n", cipherHint, clearHint)
+		if err := solved.SetSolved(cipherHint, clearHint, Reason{Kind: HintReason}); err != nil {
+			return nil, err
+		}
+	}
+	solved.exclude(puzzle.NotHints)
+	if err := solved.SetSolved('\'', '\'', Reason{Kind: NotEncipheredReason}); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(w, "%d  total cipher words\n", len(puzzle.Words))
+	fmt.Fprintf(w, "%d unique cipher words\n", len(puzzle.UniqueWords))
+	fmt.Fprintf(w, "%d  total cipher letters\n", len(solved.CipherLetters))
+
+	shapeDictCharacterization(w, s.ShapeDict, "unfiltered clear text")
+
+	// Cipher words that aren't in the dictionary spoil the letters
+	// found from other words, so start over after deciding that a
+	// cipher word is missing from the dictionary.
+	relaxed := make(map[string]bool)
+	start := solved.Snapshot()
+	totalCycles := 0
+	var words [][]byte
+	var state *cycleState
+	for {
+		relaxUnshapedWords(w, s.ShapeDict, puzzle.UniqueWords, relaxed, opts.Missing)
+		words = constrainedWords(puzzle.UniqueWords, relaxed)
+
+		var err error
+		state, err = s.runCycles(ctx, puzzle, words, solved, opts, len(relaxed) < opts.Missing)
+		if err != nil {
+			return nil, err
+		}
+		totalCycles += state.cycles
+		if len(state.unmatched) == 0 || len(relaxed) >= opts.Missing {
+			break
+		}
+		sort.Strings(state.unmatched)
+		fmt.Fprintf(w, "cipher word %q matches no dictionary words, treating it as missing from dictionary\n", state.unmatched[0])
+		fmt.Fprintf(w, "---restarting cycles---\n\n")
+		relaxed[state.unmatched[0]] = true
+		solved.Rollback(start)
+		solved.Problems = nil
+	}
+	shapeDict := state.shapeDict
+
+	var guessed []rune
+	if opts.Hybrid && !solved.Complete() {
+		if s.Ngrams == nil {
+			return nil, fmt.Errorf("hybrid scoring needs an n-gram model")
+		}
+		// The cycles' candidate letters usually leave few enough
+		// combinations to try them all.
+		guessed = chooseByFitness(solved, s.Ngrams, puzzle.Words, state.possibleLetters, opts.EncodeSelf)
+		if len(guessed) > 0 {
+			printSolvedLetters(solved)
+			fmt.Fprintln(w, "\nSolved Puzzle:")
+			printSolvedWords(w, puzzle.Words, solved)
+		}
+	}
+
+	var keys []map[rune]rune
+	if opts.Solutions > 0 {
+		// Find all the keys, rather than just the first one
+		keys = enumerate(ctx, solved, shapeDict, words, opts.EncodeSelf, opts.Solutions)
+		printSolvedLetters(solved)
+	} else if opts.Backtrack && !solved.Complete() {
+		// The cycles only mark letters that are forced. Branch on the
+		// cipher words' remaining candidates to find the rest.
+		found, ambiguous := backtrack(ctx, solved, shapeDict, words, opts.EncodeSelf)
+		guessed = append(guessed, ambiguous...)
+		if found {
+			printSolvedLetters(solved)
+			fmt.Fprintln(w, "\nSolved Puzzle:")
+			printSolvedWords(w, puzzle.Words, solved)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result := newResult(puzzle, solved, totalCycles, state.possibleLetters)
+	result.separateGuessed(guessed)
+	result.WordCandidates = make(map[string]int)
+	fullSearch := newSearch(solved, s.ShapeDict, puzzle.UniqueWords, opts.EncodeSelf, 0)
+	lastSearch := newSearch(solved, shapeDict, puzzle.UniqueWords, opts.EncodeSelf, 0)
+	for n, word := range lastSearch.words {
+		if relaxed[string(word)] {
+			// the last cycle's shape dictionary doesn't bother with missing words
+			result.WordCandidates[string(word)] = len(fullSearch.candidates(n))
+			continue
+		}
+		result.WordCandidates[string(word)] = len(lastSearch.candidates(n))
+	}
+	result.Solutions = s.rankSolutions(puzzle, keys)
+	printSolutions(w, result.Solutions)
+	for _, word := range puzzle.UniqueWords {
+		if relaxed[string(word)] {
+			missing := MissingWord{
+				CipherWord: string(word),
+				ClearWord:  clearWord(word, solved.SolvedLetters),
+			}
+			fmt.Fprintf(w, "assumed cipher word %q missing from dictionary, deciphers as %q\n",
+				missing.CipherWord, missing.ClearWord,
+			)
+			result.Missing = append(result.Missing, missing)
+		}
+	}
+
+	return result, nil
+}
+
+// cycleState is where runCycles left off
+type cycleState struct {
+	shapeDict       map[string][]string // the last cycle's shape dictionary
+	possibleLetters map[rune]LetterSet  // the last cycle's candidate clear letters
+	cycles          int                 // number of cycles run
+	unmatched       []string            // cipher words matching no dictionary words
+}
+
+// runCycles cycles through the steps of finding clear text letters for
+// the cipher letters of words, the puzzle's unique words less any
+// words missing from the dictionary. With stopOnUnmatched set, it
+// stops after a cycle in which some cipher words match no dictionary
+// words.
+func (s *Solver) runCycles(ctx context.Context, puzzle *Puzzle, words [][]byte, solved *Solved, opts Options, stopOnUnmatched bool) (*cycleState, error) {
+	w := solved.out()
+	shapeDict := limitShapeDict(s.ShapeDict, words)
+
+	// find all the dictionary words "shapes", and match up the letters with
+	// those shapes.
+	// The word "goober" would have the shape "011234".
+	// "goober" would add 'g' to position 0 of words with shape "011234",
+	// add 'o' to position 1 of words with shape "011234",
+	// add 'o' to position 2 of words with shape "011234",
+	// add 'b' to position 3 of words with shape "011234",
+	// etc etc
+	allLetters := solved.Alphabet.RunesDict(shapeDict)
+
+	state := &cycleState{}
+	cycle := 0
+	stalled := false
+	for ; !stalled && !solved.Complete() && cycle < opts.Cycles; cycle++ {
+
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("cycle %d: %w", cycle, err)
+		}
+
+		solvedBefore := len(solved.SolvedLetters)
+		wordsBefore := shapeDictWordCount(shapeDict)
+
+		fmt.Fprintf(w, "---start cycle %d---\n\n", cycle)
+
+		shapeDictCharacterization(w, shapeDict, fmt.Sprintf("cycle %d", cycle))
+
+		// map of cipher letters to correpsonding set of clear text letters
+		// that get found during this cycle.
+		possibleLetters := make(map[rune]LetterSet)
+		state.possibleLetters = possibleLetters
+		// cipher words whose same-shape words' letters got intersected
+		// for each cipher letter
+		intersected := make(map[rune][]string)
+
+		// look through all the puzzle words and find the intersection of
+		// all the sets-of-cleartext-letters for any given cipher letter
+		seenWordAlready := make(map[string]bool)
+		for _, str := range words {
+
+			// Doesn't pay off to examine the same word several times
+			if seenWordAlready[string(str)] {
+				continue
+			}
+			seenWordAlready[string(str)] = true
+
+			config := StringConfiguration(string(str))
+			fmt.Fprintf(w, "\ncipher word under consideration: %s\ncipher word shape %s\n", str, config)
+
+			configMatches := shapeDict[config]
+			fmt.Fprintf(w, "\t%d shape matches on %q\n", len(configMatches), config)
+			if len(configMatches) < 6 {
+				for i := range configMatches {
+					fmt.Fprintf(w, "\t%s\n", configMatches[i])
+				}
+			}
+
+			if entry, ok := allLetters[config]; ok {
+				cipherRunes := []rune(string(str))
+				for i := 0; i < entry.Length; i++ {
+					// all the letters found at index i in all clear text words with this configuration
+					cipherLetter := cipherRunes[i]
+					if unicode.IsPunct(cipherLetter) {
+						continue
+					}
+					if sl, ok := solved.SolvedLetters[cipherLetter]; ok {
+						// This cipher letter has a clear text letter
+						if opts.Verbose {
+							fmt.Fprintf(w, "cipher letter %c already has a solved clear text letter %c\n", cipherLetter, sl)
+						}
+						possibleLetters[cipherLetter] = solved.Alphabet.Set(sl)
+						continue
+					}
+
+					addWord(intersected, cipherLetter, string(str))
+					if clearLetters, ok := possibleLetters[cipherLetter]; ok {
+						if opts.Verbose {
+							printLetters(w, solved.Alphabet, cipherLetter, "currently associated with", clearLetters)
+						}
+						hadN := clearLetters.Len()
+						// find common letters in clearLetters and entry.Runes[i]
+						possibleLetters[cipherLetter] = entry.Runes[i] & clearLetters
+						if opts.Verbose {
+							hasN := possibleLetters[cipherLetter].Len()
+							fmt.Fprintf(w, "cipher letter %c had %d clear letters, has %d\n", cipherLetter, hadN, hasN)
+							printLetters(w, solved.Alphabet, cipherLetter, "now associated with", possibleLetters[cipherLetter])
+						}
+					} else {
+						// leave already solved cipher-letter-solutions out of possibleLetters,
+						// cipherLetter itself isn't solved
+						possibleLetters[cipherLetter] = entry.Runes[i] &^ solved.ClearLetters
+						printLetters(w, solved.Alphabet, cipherLetter, "begins cycle with", possibleLetters[cipherLetter])
+					}
+				}
+				fmt.Fprintln(w)
+			} else {
+				fmt.Fprintf(w, "Did not find letters for %s, configuration %s\n", str, config)
+			}
+		}
+
+		printSortedPossible(w, solved.Alphabet, cycle, possibleLetters)
+		if !opts.EncodeSelf {
+			// in real Cryptoquips, Cryptoquotes and Celebrity Ciphers,
+			// a cipherletter isn't itself as a clearletter
+			for cipherletter, matches := range possibleLetters {
+				if solved.Alphabet.Has(matches, cipherletter) {
+					if opts.Verbose {
+						fmt.Fprintf(w, "deleting %c from matching clearletter for %c\n", cipherletter, cipherletter)
+					}
+					possibleLetters[cipherletter] = solved.Alphabet.Remove(matches, cipherletter)
+				}
+			}
+		}
+		for cipherletter, excluded := range solved.Excluded {
+			if matches, ok := possibleLetters[cipherletter]; ok && matches&excluded != 0 {
+				if opts.Verbose {
+					fmt.Fprintf(w, "deleting %s from matching clearletter for %c, hint\n", solved.Alphabet.SetString(matches&excluded), cipherletter)
+				}
+				possibleLetters[cipherletter] = matches &^ excluded
+			}
+		}
+
+		// no two cipher letters have the same clear text letter, which
+		// can narrow the sets of cleartext letters more than solved
+		// letters alone do.
+		narrowed := allDifferent(solved, possibleLetters)
+
+		// if any ciper letters have a set of cleartext letters of size 1,
+		// mark those cipher letters as solved.
+		markSingleSolvedLettes(solved, possibleLetters, intersected, narrowed)
+
+		// Compose patterns for each puzzle (cipher) word based
+		// on the sets of cleartext letters.
+		shapeMatches, err := cwMustMatch(solved, words, possibleLetters)
+		if err != nil {
+			return nil, fmt.Errorf("cycle %d: %w", cycle, err)
+		}
+
+		// recreate a "shape dictionary" based on words that match the
+		// patterns, and exist in the current shape dictionary.
+		var unmatched []string
+		shapeDict, unmatched = shapeDictFromPatterns(solved, shapeDict, shapeMatches)
+
+		// drop the words that no dictionary word of some other cipher
+		// word sharing cipher letters agrees with.
+		shapeDict = arcConsistency(solved, shapeDict, words, opts.EncodeSelf)
+		shapeDictCharacterization(w, shapeDict, "new")
+
+		// Figure out the sets of clear text letters associated with each
+		// cipher letter from the newly re-created shape dictionary.
+		// Solved cleartext letters don't get removed here.
+		allLetters = solved.Alphabet.RunesDict(shapeDict)
+
+		printSolvedLetters(solved)
+
+		fmt.Fprintln(w, "\nSolved Puzzle:")
+		printSolvedWords(w, puzzle.Words, solved)
+
+		fmt.Fprintf(w, "---end cycle %d---\n\n", cycle)
+
+		searching := opts.Backtrack || opts.Solutions > 0
+		if searching && solvedBefore == len(solved.SolvedLetters) && wordsBefore == shapeDictWordCount(shapeDict) {
+			fmt.Fprintf(w, "cycle %d made no progress\n\n", cycle)
+			stalled = true
+		}
+
+		if stopOnUnmatched && len(unmatched) > 0 {
+			state.unmatched = unmatched
+			cycle++
+			break
+		}
+	}
+
+	state.shapeDict = shapeDict
+	state.cycles = cycle
+	return state, nil
+}
+
+// rankSolutions deciphers the puzzle with each of keys, and sorts
+// the Solutions by score, highest first. Scores are summed log word
+// frequencies if the Solver has word counts, summed log letter
+// frequencies of the Solver's alphabet otherwise.
+func (s *Solver) rankSolutions(puzzle *Puzzle, keys []map[rune]rune) []*Solution {
+	var solutions []*Solution
+	total := countsTotal(s.Counts)
+	frequencies := letterFrequencies
+	if len(s.Counts) == 0 && !s.Alphabet.same(English) {
+		frequencies = dictLetterFrequencies(s.Alphabet, s.ShapeDict)
+	}
+	for _, key := range keys {
+		solution := &Solution{Key: key}
+		for _, word := range puzzle.Words {
+			solution.Words = append(solution.Words, clearWord(word, key))
+		}
+		if len(s.Counts) > 0 {
+			solution.Score = wordScore(solution.Words, s.Counts, total)
+		} else {
+			solution.Score = letterScore(solution.Words, frequencies)
+		}
+		solutions = append(solutions, solution)
+	}
+	sort.SliceStable(solutions, func(i, j int) bool {
+		return solutions[i].Score > solutions[j].Score
+	})
+	return solutions
+}
+
+// newResult composes a Result from the solved letters of a puzzle,
+// and the candidate clear text letters of the unsolved letters.
+func newResult(puzzle *Puzzle, solved *Solved, cycles int, possibleLetters map[rune]LetterSet) *Result {
+	result := &Result{
+		Key:        make(map[rune]rune),
+		Cycles:     cycles,
+		Candidates: make(map[rune][]rune),
+	}
+	for _, cipherLetter := range solved.CipherLetters {
+		if clearLetter, ok := solved.SolvedLetters[cipherLetter]; ok {
+			result.Key[cipherLetter] = clearLetter
+			result.Solved = append(result.Solved, cipherLetter)
+			result.Candidates[cipherLetter] = []rune{clearLetter}
+			continue
+		}
+		result.Unsolved = append(result.Unsolved, cipherLetter)
+		// Runes are already alphabetized
+		result.Candidates[cipherLetter] = solved.Alphabet.Runes(possibleLetters[cipherLetter] &^ solved.ClearLetters)
+	}
+	sort.Sort(RuneSlice(result.Solved))
+	sort.Sort(RuneSlice(result.Unsolved))
+	for _, a := range solved.Trail {
+		if _, ok := result.Key[a.CipherLetter]; ok {
+			result.Deductions = append(result.Deductions, a)
+		}
+	}
+	for _, word := range puzzle.Words {
+		result.Words = append(result.Words, clearWord(word, solved.SolvedLetters))
+	}
+	result.ClearText = puzzle.Decipher(solved.SolvedLetters)
+	result.Problems = solved.Problems
+	return result
+}
+
+// separateGuessed moves the cipher letters whose clear text letters
+// got chosen by n-gram fitness, rather than forced, from Solved to Guessed.
+func (r *Result) separateGuessed(guessed []rune) {
+	if len(guessed) == 0 {
+		return
+	}
+	isGuessed := make(map[rune]bool)
+	for _, cipherLetter := range guessed {
+		isGuessed[cipherLetter] = true
+	}
+	var forced []rune
+	for _, cipherLetter := range r.Solved {
+		if isGuessed[cipherLetter] {
+			r.Guessed = append(r.Guessed, cipherLetter)
+			continue
+		}
+		forced = append(forced, cipherLetter)
+	}
+	r.Solved = forced
+}
+
+// clearWord deciphers a cipher word with a key of cipher letters
+// to clear text letters, putting '?' in place of cipher letters
+// not in the key.
+func clearWord(word []byte, key map[rune]rune) string {
+	clear := make([]rune, 0, len(word))
+	for _, r := range string(word) {
+		x := '?'
+		if c, ok := key[r]; ok {
+			x = c
+		}
+		clear = append(clear, x)
+	}
+	return string(clear)
+}
+
+// markSingleSolvedLettes trys to mark as solved any cipher letters that
+// have a single possible letter left. Var possibleLetters contains the
+// clear text letters left after intersecting the possible letters from
+// the shape-keyed dictionary, and intersected the cipher words whose
+// same-shape words got intersected for each cipher letter. Var narrowed
+// has the reasons for the letters that allDifferent narrowed further.
+func markSingleSolvedLettes(solved *Solved, possibleLetters map[rune]LetterSet, intersected map[rune][]string, narrowed map[rune]Reason) {
+	// alphabetical order keeps the trail of deductions the same run to run
+	for _, cipherLetter := range solved.CipherLetters {
+		if singleLetter, ok := solved.Alphabet.Only(possibleLetters[cipherLetter]); ok {
+			reason, ok := narrowed[cipherLetter]
+			if !ok {
+				reason = Reason{
+					Kind:  IntersectionReason,
+					Words: intersected[cipherLetter],
+				}
+			}
+			solved.mark(cipherLetter, singleLetter, reason)
+		}
+	}
+}
+
+// addWord appends cipher word to the words of cipherLetter, unless
+// it's already the last one, as it is for a letter appearing twice.
+func addWord(words map[rune][]string, cipherLetter rune, word string) {
+	w := words[cipherLetter]
+	if len(w) > 0 && w[len(w)-1] == word {
+		return
+	}
+	words[cipherLetter] = append(w, word)
+}
+
+// relaxUnshapedWords marks as missing from the dictionary any of words
+// whose shape no dictionary word has, as long as there are fewer than
+// limit missing words.
+func relaxUnshapedWords(w io.Writer, totalShapeDict map[string][]string, words [][]byte, relaxed map[string]bool, limit int) {
+	for _, word := range words {
+		if len(relaxed) >= limit {
+			return
+		}
+		if relaxed[string(word)] {
+			continue
+		}
+		if len(totalShapeDict[StringConfiguration(string(word))]) == 0 {
+			fmt.Fprintf(w, "no dictionary words have the shape of cipher word %q, treating it as missing from dictionary\n", word)
+			relaxed[string(word)] = true
+		}
+	}
+}
+
+// constrainedWords returns the words not marked as missing from the dictionary.
+func constrainedWords(words [][]byte, relaxed map[string]bool) [][]byte {
+	var constrained [][]byte
+	for _, word := range words {
+		if !relaxed[string(word)] {
+			constrained = append(constrained, word)
+		}
+	}
+	return constrained
+}
+
+// limitShapeDict called on the shape dictionary derived from the whole clear
+// text dictionary, and the list of puzzle words. Called before the first
+// cycle, so it doesn't have to deal with a shape dictionary that has shapes
+// not found in the cipher letters
+func limitShapeDict(totalShapeDict map[string][]string, puzzlewords [][]byte) map[string][]string {
+
+	shapeDict := make(map[string][]string)
+	seenWordAlready := make(map[string]bool)
+
+	for _, wordBytes := range puzzlewords {
+		word := string(wordBytes)
+		if seenWordAlready[word] {
+			continue
+		}
+		cfg := StringConfiguration(word)
+		shapeDict[cfg] = totalShapeDict[cfg]
+	}
+
+	return shapeDict
+}
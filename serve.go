@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cryptoquip/qp"
+)
+
+// server answers HTTP requests with a dictionary read once at startup.
+type server struct {
+	solver  *qp.Solver
+	letters map[string]*qp.Entry // dictionary letters by shape, for /shape
+	timeout time.Duration        // longest a single /solve gets
+}
+
+// solveRequest is the body of a POST /solve. Absent fields keep
+// the same defaults as the solver command's flags.
+type solveRequest struct {
+	Puzzle     string            `json:"puzzle"` // text of a puzzle file, hint lines and all
+	Hints      map[string]string `json:"hints"`  // more hints, cipher letter to clear text letter
+	Method     string            `json:"method"` // shape, or ngram
+	Cycles     int               `json:"cycles"`
+	EncodeSelf bool              `json:"encode_self"`
+	Backtrack  bool              `json:"backtrack"`
+	Solutions  int               `json:"solutions"`
+	Missing    int               `json:"missing"`
+	Hybrid     bool              `json:"hybrid"`
+	Restarts   int               `json:"restarts"`
+	Seed       int64             `json:"seed"`
+}
+
+// encodeRequest is the body of a POST /encode
+type encodeRequest struct {
+	Text string `json:"text"`
+	Seed int64  `json:"seed"` // 0 picks one
+}
+
+const maxRequestBytes = 1 << 20
+
+func main() {
+	dictName := flag.String("d", "/usr/share/dict/words", "cleartext dictionary")
+	language := flag.String("lang", "english", "cleartext language, one of "+strings.Join(qp.Languages(), ", ")+", or an alphabet file")
+	fold := flag.Bool("fold", false, "fold accented letters into unaccented ones")
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	timeout := flag.Duration("timeout", 10*time.Second, "longest time to spend solving a single puzzle")
+	ngramLength := flag.Int("ngram", 3, "letter n-gram length for the ngram method and hybrid, 0 for neither")
+	flag.Parse()
+
+	alphabet, err := qp.LanguageAlphabet(*language, *fold)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dict, err := qp.LoadDictionary(*dictName, alphabet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dict.IndexError != nil {
+		log.Printf("not using shape index: %v", dict.IndexError)
+	}
+	srv := &server{
+		solver:  &qp.Solver{ShapeDict: dict.Shapes, Counts: dict.Counts, Alphabet: dict.Alphabet},
+		letters: dict.RunesDict(),
+		timeout: *timeout,
+	}
+	if *ngramLength > 0 {
+		srv.solver.Ngrams, err = qp.NewNgramModel(*dictName, *ngramLength, dict.Alphabet)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/solve", srv.solve)
+	mux.HandleFunc("/encode", srv.encode)
+	mux.HandleFunc("/shape", srv.shape)
+
+	log.Printf("dictionary %s, alphabet %s, listening on %s", *dictName, dict.Alphabet, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func (srv *server) solve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s /solve, should be POST", r.Method))
+		return
+	}
+	req := solveRequest{
+		Method:     "shape",
+		Cycles:     8,
+		EncodeSelf: true,
+		Backtrack:  true,
+		Restarts:   20,
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	puzzle, err := qp.ParsePuzzle([]byte(req.Puzzle))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(puzzle.Words) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("puzzle has no cipher words"))
+		return
+	}
+	for cipher, clear := range req.Hints {
+		cipherLetters, clearLetters := []rune(cipher), []rune(clear)
+		if len(cipherLetters) != 1 || len(clearLetters) != 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("hint %q = %q, should be single letters", cipher, clear))
+			return
+		}
+		if err := puzzle.AddHint(cipherLetters[0], clearLetters[0]); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	strategy := qp.ShapeStrategy
+	switch req.Method {
+	case "shape":
+	case "ngram":
+		strategy = qp.NgramStrategy
+		if req.Seed == 0 {
+			req.Seed = time.Now().UnixNano()
+		}
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown solving method %q", req.Method))
+		return
+	}
+	if (strategy == qp.NgramStrategy || req.Hybrid) && srv.solver.Ngrams == nil {
+		writeError(w, http.StatusBadRequest, errors.New("server has no n-gram model"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), srv.timeout)
+	defer cancel()
+	result, err := srv.solver.SolveContext(ctx, puzzle, qp.Options{
+		Strategy:   strategy,
+		Cycles:     req.Cycles,
+		EncodeSelf: req.EncodeSelf,
+		Backtrack:  req.Backtrack,
+		Solutions:  req.Solutions,
+		Missing:    req.Missing,
+		Hybrid:     req.Hybrid,
+		Restarts:   req.Restarts,
+		Seed:       req.Seed,
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("solving took longer than %v", srv.timeout))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Puzzle *qp.Puzzle `json:"puzzle"`
+		Result *qp.Result `json:"result"`
+	}{
+		Puzzle: puzzle,
+		Result: result,
+	})
+}
+
+func (srv *server) encode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s /encode, should be POST", r.Method))
+		return
+	}
+	var req encodeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Seed == 0 {
+		req.Seed = time.Now().UnixNano()
+	}
+	alphabet := srv.solver.Alphabet
+	txp := qp.RandomKey(alphabet, rand.New(rand.NewSource(req.Seed)))
+	cipherText, clears := qp.Encipher(alphabet, req.Text, txp)
+
+	// same direction as a solved key, cipher letter to clear text letter
+	key := make(map[string]string)
+	for _, clear := range clears {
+		key[string(txp[clear])] = string(clear)
+	}
+	writeJSON(w, http.StatusOK, struct {
+		CipherText string            `json:"cipher_text"`
+		Key        map[string]string `json:"key"`
+		Seed       int64             `json:"seed"`
+	}{
+		CipherText: cipherText,
+		Key:        key,
+		Seed:       req.Seed,
+	})
+}
+
+func (srv *server) shape(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s /shape, should be GET", r.Method))
+		return
+	}
+	word := srv.solver.Alphabet.Normalize(r.URL.Query().Get("word"))
+	if word == "" {
+		writeError(w, http.StatusBadRequest, errors.New("need a word"))
+		return
+	}
+	config := qp.StringConfiguration(word)
+
+	// clear text letters at each position of the matches
+	letters := []string{}
+	if entry, ok := srv.letters[config]; ok {
+		for i := 0; i < entry.Length; i++ {
+			letters = append(letters, srv.solver.Alphabet.SetString(entry.Runes[i]))
+		}
+	}
+	matches := srv.solver.ShapeDict[config]
+	if matches == nil {
+		matches = []string{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Word    string   `json:"word"`
+		Shape   string   `json:"shape"`
+		Matches []string `json:"matches"`
+		Letters []string `json:"letters"`
+	}{
+		Word:    word,
+		Shape:   config,
+		Matches: matches,
+		Letters: letters,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "writing response: %v\n", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{
+		Error: err.Error(),
+	})
+}